@@ -0,0 +1,182 @@
+package gcr
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+	"github.com/seeeverything/notary-gcr/trust"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// DefaultParallelism is the number of references VerifyImages verifies at
+// the same time, unless WithParallelism is used.
+const DefaultParallelism = 8
+
+// VerifyResult is the outcome of verifying one of the references passed to
+// VerifyImages. Err is the error VerifyImage would have returned for
+// Reference; use errors.Is with the trust package errors to tell failures
+// apart.
+type VerifyResult struct {
+	Reference name.Reference
+	Digest    name.Digest
+	Target    *client.Target
+	Err       error
+}
+
+// VerifyImages verifies refs like VerifyImage, with at most
+// DefaultParallelism references, or that of WithParallelism, verified at
+// the same time. The trust data of each repository is downloaded once for
+// all the references into it. The results are in the order of refs. opts
+// configure the verification as for NewTrustedGcrRepositoryWithOptions.
+func VerifyImages(ctx context.Context, refs []name.Reference, auth authn.Authenticator, opts ...Option) []VerifyResult {
+	o := makeOptions(opts...)
+	if shareConfig(&o.config) {
+		defer o.config.RepositoryCache.Close()
+	}
+	return verifyImages(ctx, refs, auth, &o.config, o.parallelism)
+}
+
+func verifyImages(ctx context.Context, refs []name.Reference, auth authn.Authenticator, config *trust.Config, parallelism int) []VerifyResult {
+	results := make([]VerifyResult, len(refs))
+	// references to the same repository share its trust data
+	var keys []string
+	groups := make(map[string][]int)
+	for i, ref := range refs {
+		results[i].Reference = ref
+		key := ref.Context().RegistryStr() + " " + config.GUN(ref).String()
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	slots := make(chan struct{}, parallelism)
+	acquire := func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		select {
+		case slots <- struct{}{}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		wg.Add(1)
+		go func(indexes []int) {
+			defer wg.Done()
+			ref := refs[indexes[0]]
+			gun := config.GUN(ref)
+			auth, err := config.RegistryAuth(ref.Context().Registry, auth)
+			if err != nil {
+				for _, i := range indexes {
+					results[i].Err = err
+				}
+				return
+			}
+
+			// the trust data is only needed for the tags not cached
+			cached := make(map[int]*client.TargetWithRole)
+			for _, i := range indexes {
+				if tag, ok := refs[i].(name.Tag); ok {
+					if t, ok := config.TargetCache.Get(gun, tag.Identifier()); ok {
+						cached[i] = t
+					}
+				}
+			}
+			var (
+				targets     map[string]*client.TargetWithRole
+				unavailable bool
+			)
+			if len(cached) < len(indexes) {
+				if err = acquire(); err != nil {
+					for _, i := range indexes {
+						results[i].Err = err
+					}
+					return
+				}
+				targets, unavailable, err = trustedTargets(ctx, ref, auth, config)
+				<-slots
+			}
+
+			for _, i := range indexes {
+				wg.Add(1)
+				go func(r *VerifyResult, t *client.TargetWithRole) {
+					defer wg.Done()
+					if r.Err = acquire(); r.Err != nil {
+						return
+					}
+					defer func() { <-slots }()
+					if t == nil {
+						if t, r.Err = targetOf(config, gun, targets, unavailable, err, r.Reference); r.Err != nil {
+							return
+						}
+					}
+					r.Target = &t.Target
+					if r.Digest, r.Err = verifyManifest(ctx, r.Reference, r.Target, auth, config); r.Err != nil {
+						r.Target = nil
+					}
+				}(&results[i], cached[i])
+			}
+		}(groups[key])
+	}
+	wg.Wait()
+	return results
+}
+
+// trustedTargets returns the targets signed in the repository of ref, by
+// name, as getTrustedTarget would find them. unavailable reports whether
+// the error is that the notary server could not be reached.
+func trustedTargets(ctx context.Context, ref name.Reference, auth authn.Authenticator, config *trust.Config) (targets map[string]*client.TargetWithRole, unavailable bool, err error) {
+	repoInfo := ref.Context().Registry
+	notaryRepo, release, err := trust.AcquireNotaryRepository(ctx, ref, auth, &repoInfo, config, true)
+	if err != nil {
+		return nil, errors.Is(err, trust.ErrNetwork), errors.Wrap(err, "error establishing connection to trust repository")
+	}
+	defer release()
+
+	list, err := notaryRepo.ListTargets(trust.ReleasesRole, data.CanonicalTargetsRole)
+	if err != nil {
+		// the error is shared by all the references into the repository
+		err = trust.NotaryError(ref.Context().Name(), err)
+		return nil, errors.Is(err, trust.ErrNetwork), err
+	}
+	targets = make(map[string]*client.TargetWithRole, len(list))
+	for _, t := range list {
+		// as in trustedTarget, ignore the targets of other delegation roles
+		if t.Role == trust.ReleasesRole || t.Role == data.CanonicalTargetsRole {
+			targets[t.Name] = t
+		}
+	}
+	return targets, false, nil
+}
+
+// targetOf returns the target of the tag of ref in targets, the targets of
+// gun, and caches it in config.TargetCache. If targets could not be
+// downloaded, it returns err, or the stale cached target if the notary
+// server was unavailable.
+func targetOf(config *trust.Config, gun data.GUN, targets map[string]*client.TargetWithRole, unavailable bool, err error, ref name.Reference) (*client.TargetWithRole, error) {
+	tag, tagErr := name.NewTag(ref.String(), name.StrictValidation)
+	if tagErr != nil {
+		return nil, errors.Wrap(tagErr, "couldn't parse tag from repository name")
+	}
+	if err != nil {
+		if unavailable {
+			return staleTarget(config, gun, tag.Identifier(), err)
+		}
+		return nil, err
+	}
+	t, ok := targets[tag.Identifier()]
+	if !ok {
+		return nil, trust.NotaryError(ref.Name(), client.ErrNoSuchTarget(tag.Identifier()))
+	}
+	config.TargetCache.Add(gun, tag.Identifier(), t)
+	return t, nil
+}
@@ -0,0 +1,67 @@
+package gcr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/seeeverything/notary-gcr/trust"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestVerifyImages(t *testing.T) {
+	var mu sync.Mutex
+	roots := map[string]int{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/_trust/tuf/root.json") {
+			mu.Lock()
+			roots[r.URL.Path]++
+			mu.Unlock()
+		}
+		if r.URL.Path != "/v2/" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var refs []name.Reference
+	for _, s := range []string{"example.com/foo:1", "example.com/bar:1", "example.com/foo:2", "example.com/foo@sha256:" + strings.Repeat("a", 64)} {
+		ref, err := name.ParseReference(s, name.WeakValidation)
+		assert.NilError(t, err)
+		refs = append(refs, ref)
+	}
+
+	results := VerifyImages(context.Background(), refs, nil,
+		WithServerURL(server.URL),
+		WithTransport(server.Client().Transport),
+		WithInMemory(nil),
+		WithParallelism(2),
+	)
+	assert.Assert(t, is.Len(results, len(refs)))
+	for i, r := range results[:3] {
+		assert.Check(t, is.Equal(r.Reference, refs[i]))
+		assert.Check(t, errors.Is(r.Err, trust.ErrNoTrustData), "%s: %v", r.Reference, r.Err)
+		assert.Check(t, r.Target == nil)
+	}
+	// the error names the repository, not the first reference into it
+	assert.Check(t, !strings.Contains(results[2].Err.Error(), "example.com/foo:1"), "%v", results[2].Err)
+	assert.Check(t, is.ErrorContains(results[2].Err, "example.com/foo"))
+	assert.Check(t, is.ErrorContains(results[3].Err, "couldn't parse tag"))
+	assert.Check(t, is.DeepEqual(roots, map[string]int{
+		"/v2/example.com/foo/_trust/tuf/root.json": 1,
+		"/v2/example.com/bar/_trust/tuf/root.json": 1,
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = VerifyImages(ctx, refs, nil, WithServerURL(server.URL), WithInMemory(nil))
+	for _, r := range results {
+		assert.Check(t, errors.Is(r.Err, context.Canceled), "%s: %v", r.Reference, r.Err)
+	}
+}
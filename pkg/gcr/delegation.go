@@ -0,0 +1,84 @@
+package gcr
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+	"github.com/seeeverything/notary-gcr/trust"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// updateDelegation stages the changes made by stage to the delegation role
+// in the trust data of ref's repository, and publishes them.
+func updateDelegation(ctx context.Context, ref name.Reference, role data.RoleName, auth authn.Authenticator, config *trust.Config, stage func(client.Repository) error) error {
+	if err := trust.CheckDelegationRole(role); err != nil {
+		return err
+	}
+
+	repoInfo := ref.Context().Registry
+	notaryRepo, release, err := trust.AcquireNotaryRepository(ctx, ref, auth, &repoInfo, config, false)
+	if err != nil {
+		return errors.Wrap(err, "error establishing connection to trust repository")
+	}
+	defer release()
+	// delegations decide the targets trusted for every tag
+	defer config.TargetCache.Invalidate(notaryRepo.GetGUN())
+
+	if err = clearChangeList(notaryRepo); err != nil {
+		return err
+	}
+	defer clearChangeList(notaryRepo)
+	if err := stage(notaryRepo); err != nil {
+		return trust.NotaryError(ref.Context().Name(), err)
+	}
+	if err := notaryRepo.Publish(); err != nil {
+		return trust.NotaryError(ref.Context().Name(), err)
+	}
+	config.Log().Infof("Successfully updated delegation %s of %s\n", role, ref.Context().Name())
+	return nil
+}
+
+func addDelegation(ctx context.Context, ref name.Reference, role data.RoleName, keys []data.PublicKey, paths []string, threshold int, auth authn.Authenticator, config *trust.Config) error {
+	return updateDelegation(ctx, ref, role, auth, config, func(notaryRepo client.Repository) error {
+		return trust.AddDelegation(notaryRepo, role, keys, paths, threshold)
+	})
+}
+
+func removeDelegation(ctx context.Context, ref name.Reference, role data.RoleName, auth authn.Authenticator, config *trust.Config) error {
+	return updateDelegation(ctx, ref, role, auth, config, func(notaryRepo client.Repository) error {
+		return notaryRepo.RemoveDelegationRole(role)
+	})
+}
+
+func addDelegationKeys(ctx context.Context, ref name.Reference, role data.RoleName, keys []data.PublicKey, auth authn.Authenticator, config *trust.Config) error {
+	return updateDelegation(ctx, ref, role, auth, config, func(notaryRepo client.Repository) error {
+		return notaryRepo.AddDelegationRoleAndKeys(role, keys)
+	})
+}
+
+func removeDelegationKeys(ctx context.Context, ref name.Reference, role data.RoleName, keyIDs []string, auth authn.Authenticator, config *trust.Config) error {
+	return updateDelegation(ctx, ref, role, auth, config, func(notaryRepo client.Repository) error {
+		return notaryRepo.RemoveDelegationKeys(role, keyIDs)
+	})
+}
+
+func addDelegationPaths(ctx context.Context, ref name.Reference, role data.RoleName, paths []string, auth authn.Authenticator, config *trust.Config) error {
+	return updateDelegation(ctx, ref, role, auth, config, func(notaryRepo client.Repository) error {
+		return notaryRepo.AddDelegationPaths(role, paths)
+	})
+}
+
+func removeDelegationPaths(ctx context.Context, ref name.Reference, role data.RoleName, paths []string, auth authn.Authenticator, config *trust.Config) error {
+	return updateDelegation(ctx, ref, role, auth, config, func(notaryRepo client.Repository) error {
+		return notaryRepo.RemoveDelegationPaths(role, paths)
+	})
+}
+
+func clearDelegationPaths(ctx context.Context, ref name.Reference, role data.RoleName, auth authn.Authenticator, config *trust.Config) error {
+	return updateDelegation(ctx, ref, role, auth, config, func(notaryRepo client.Repository) error {
+		return notaryRepo.ClearDelegationPaths(role)
+	})
+}
@@ -0,0 +1,72 @@
+package gcr
+
+import (
+	"fmt"
+)
+
+// ErrDigestMismatch is returned when the manifest a reference resolves to in
+// the registry is not the one signed in the trust data.
+type ErrDigestMismatch struct {
+	Reference    string
+	Signed       string
+	SignedLength int64
+	Remote       string
+	RemoteLength int64
+}
+
+func (err ErrDigestMismatch) Error() string {
+	return fmt.Sprintf("%s resolves to %s (%d bytes) in the registry, but %s (%d bytes) is signed",
+		err.Reference, err.Remote, err.RemoteLength, err.Signed, err.SignedLength)
+}
+
+// PushPhase is a step of signing, and possibly pushing, an image.
+type PushPhase string
+
+const (
+	// PhaseImagePush pushes the image to the registry.
+	PhaseImagePush PushPhase = "image push"
+	// PhaseInit initializes the trust data of the repository.
+	PhaseInit PushPhase = "init"
+	// PhaseStage fetches the trust data and stages the signed targets.
+	PhaseStage PushPhase = "stage"
+	// PhasePublish publishes the staged targets to the notary server.
+	PhasePublish PushPhase = "publish"
+)
+
+// ErrTrustPush is returned when pushing or signing an image fails. When
+// ImagePushed is set, the image is in the registry but not signed, and only
+// the signing needs to be retried.
+type ErrTrustPush struct {
+	Reference   string
+	Phase       PushPhase
+	ImagePushed bool
+	Err         error
+}
+
+func (err ErrTrustPush) Error() string {
+	msg := fmt.Sprintf("%s of %s failed: %s", err.Phase, err.Reference, err.Err)
+	if err.ImagePushed {
+		msg += " (the image was pushed but not signed)"
+	}
+	return msg
+}
+
+// Cause returns the underlying error, for errors.Cause.
+func (err ErrTrustPush) Cause() error {
+	return err.Err
+}
+
+// Unwrap returns the underlying error, for errors.Is and errors.As.
+func (err ErrTrustPush) Unwrap() error {
+	return err.Err
+}
+
+// imagePushed marks a signing failure as happening after the image was
+// pushed.
+func imagePushed(err error) error {
+	if e, ok := err.(ErrTrustPush); ok {
+		e.ImagePushed = true
+		return e
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package gcr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pkg/errors"
+	"github.com/seeeverything/notary-gcr/trust"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestErrTrustPush(t *testing.T) {
+	cause := errors.New("unauthorized")
+	err := imagePushed(ErrTrustPush{Reference: "dockerhub.com/foo/image", Phase: PhasePublish, Err: cause})
+	assert.Error(t, err, "publish of dockerhub.com/foo/image failed: unauthorized (the image was pushed but not signed)")
+
+	pushErr, ok := err.(ErrTrustPush)
+	assert.Assert(t, ok)
+	assert.Check(t, pushErr.ImagePushed)
+	assert.Check(t, is.Equal(errors.Cause(err), cause))
+
+	assert.Check(t, is.Equal(imagePushed(cause), cause))
+}
+
+// brokenImage is an image whose manifest cannot be read.
+type brokenImage struct {
+	v1.Image
+}
+
+func (brokenImage) Digest() (v1.Hash, error) {
+	return v1.Hash{}, errors.New("no manifest")
+}
+
+func TestTargetErrTrustPush(t *testing.T) {
+	ref, _ := name.ParseReference("dockerhub.com/foo/image:1.0", name.WeakValidation)
+	config := &trust.Config{InMemory: true}
+
+	_, err := pushTrustedReference(context.Background(), ref, brokenImage{}, nil, config)
+	pushErr, ok := imagePushed(err).(ErrTrustPush)
+	assert.Assert(t, ok, "%v", err)
+	assert.Check(t, is.Equal(pushErr.Phase, PhaseStage))
+	assert.Check(t, pushErr.ImagePushed)
+
+	_, err = signImageTags(context.Background(), ref, brokenImage{}, []string{"1.0"}, nil, config)
+	assert.Check(t, is.ErrorType(err, ErrTrustPush{}))
+
+	_, err = publishTargets(context.Background(), ref, nil, config)
+	pushErr, ok = err.(ErrTrustPush)
+	assert.Assert(t, ok, "%v", err)
+	assert.Check(t, is.Equal(pushErr.Phase, PhaseStage))
+}
@@ -1,18 +1,28 @@
 package gcr
 
 import (
+	"context"
+
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/seeeverything/notary-gcr/trust"
 	log "github.com/sirupsen/logrus"
 	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
 )
 
+// TrustedGcrRepository signs, verifies and revokes the trust data of an
+// image reference. It is safe for concurrent use: changes to the trust data
+// of a GUN are serialized between goroutines, and between processes sharing
+// the trust directory through a lock file per GUN.
 type TrustedGcrRepository struct {
 	ref    name.Reference
 	auth   authn.Authenticator
 	config *trust.Config
+	// ownsCache is set when config.RepositoryCache was created for the
+	// repository, and is released by Close.
+	ownsCache bool
 }
 
 func NewTrustedGcrRepository(configDir string, ref name.Reference, auth authn.Authenticator) (TrustedGcrRepository, error) {
@@ -21,49 +31,418 @@ func NewTrustedGcrRepository(configDir string, ref name.Reference, auth authn.Au
 		log.Errorf("failed to parse config: %s", err)
 		return TrustedGcrRepository{}, err
 	}
-	return TrustedGcrRepository{ref, auth, config}, nil
+	return newTrustedGcrRepository(ref, auth, config)
+}
+
+// newTrustedGcrRepository returns the TrustedGcrRepository of ref. Its
+// notary repositories and connections are cached in config, which copies
+// of the TrustedGcrRepository share.
+func newTrustedGcrRepository(ref name.Reference, auth authn.Authenticator, config *trust.Config) (TrustedGcrRepository, error) {
+	auth, err := config.RegistryAuth(ref.Context().Registry, auth)
+	if err != nil {
+		return TrustedGcrRepository{}, err
+	}
+	ownsCache := shareConfig(config)
+	return TrustedGcrRepository{ref, auth, config, ownsCache}, nil
+}
+
+// shareConfig prepares config to be shared by several operations: they
+// reuse its notary repositories and connections and, in in-memory mode,
+// its keys. It reports whether it created config.RepositoryCache, which the
+// caller then closes.
+func shareConfig(config *trust.Config) (created bool) {
+	if config.RepositoryCache == nil {
+		config.RepositoryCache = trust.NewRepositoryCache(0)
+		created = true
+	}
+	if config.InMemory && config.CryptoService == nil {
+		config.CryptoService = trust.NewMemoryCryptoService(config)
+	}
+	return created
+}
+
+// Close releases the notary repositories and connections cached by the
+// repository and its copies, unless the cache was provided with WithConfig.
+// The repository can still be used afterwards, reconnecting to the notary
+// server.
+func (repo *TrustedGcrRepository) Close() error {
+	if repo.ownsCache {
+		repo.config.RepositoryCache.Close()
+	}
+	return nil
+}
+
+// NewTrustedGcrRepositoryWithOptions returns a TrustedGcrRepository
+// configured in memory by opts; unlike NewTrustedGcrRepository it does not
+// read any configuration file. The trust directory defaults to
+// DefaultConfigDir, unless WithInMemory is used. A nil auth resolves the
+// credentials with the keychain of WithKeychain, or accesses public
+// repositories anonymously.
+func NewTrustedGcrRepositoryWithOptions(ref name.Reference, auth authn.Authenticator, opts ...Option) (TrustedGcrRepository, error) {
+	o := makeOptions(opts...)
+	return newTrustedGcrRepository(ref, auth, &o.config)
+}
+
+// Initialize creates the trust data of the repository of the reference and
+// publishes it. By default it is signed with the first root key of the
+// trust directory, or a new one, and the notary server manages the
+// snapshot key.
+func (repo *TrustedGcrRepository) Initialize(ctx context.Context, opts ...InitOption) error {
+	err := initialize(ctx, repo.ref, repo.auth, repo.config, makeInitOptions(opts...))
+	if err != nil {
+		repo.config.Log().Errorf("failed to initialize repository: %s", err)
+		return err
+	}
+	return nil
 }
 
-func (repo *TrustedGcrRepository) ListTarget() ([]*client.Target, error) {
-	targets, err := listTargets(repo.ref, repo.auth, repo.config)
+// ListTarget returns the targets signed in the repository of the reference,
+// with the role each was signed into.
+func (repo *TrustedGcrRepository) ListTarget() ([]*client.TargetWithRole, error) {
+	return repo.ListTargetContext(context.Background())
+}
+
+// ListTargetContext is like ListTarget, but aborts the notary round trips
+// when ctx is done.
+func (repo *TrustedGcrRepository) ListTargetContext(ctx context.Context) ([]*client.TargetWithRole, error) {
+	targets, err := listTargets(ctx, repo.ref, repo.auth, repo.config)
 	if err != nil {
-		log.Errorf("failed to list targets: %s", err)
+		repo.config.Log().Errorf("failed to list targets: %s", err)
 		return nil, err
 	}
 	return targets, nil
 }
 
-func (repo *TrustedGcrRepository) TrustPush(img v1.Image) error {
-	err := pushImage(repo.ref, img, repo.auth)
+// TrustPush pushes img to the registry and signs it under the tag of the
+// reference. Failures are returned as ErrTrustPush; if the image was pushed
+// but not signed, ImagePushed is set and SignImage can be retried alone.
+func (repo *TrustedGcrRepository) TrustPush(img v1.Image) (*trust.SignResult, error) {
+	return repo.TrustPushContext(context.Background(), img)
+}
+
+// TrustPushContext is like TrustPush, but aborts both the registry push and
+// the notary round trips when ctx is done.
+func (repo *TrustedGcrRepository) TrustPushContext(ctx context.Context, img v1.Image) (*trust.SignResult, error) {
+	if repo.config.ReadOnly {
+		return nil, trust.ErrReadOnly
+	}
+	var result *trust.SignResult
+	err := pushImage(ctx, repo.ref, img, repo.auth, repo.config)
+	if err == nil {
+		result, err = pushTrustedReference(ctx, repo.ref, img, repo.auth, repo.config)
+		err = imagePushed(err)
+	}
+	if err != nil {
+		repo.config.Log().Errorf("failed to push trusted image: %s", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// TrustPushIndex pushes the multi-platform image index idx to the registry
+// and signs it under the tag of the reference. The first result is the
+// index target, followed by the platform targets.
+func (repo *TrustedGcrRepository) TrustPushIndex(ctx context.Context, idx v1.ImageIndex, opts ...IndexOption) ([]*trust.SignResult, error) {
+	if repo.config.ReadOnly {
+		return nil, trust.ErrReadOnly
+	}
+	var results []*trust.SignResult
+	err := pushIndex(ctx, repo.ref, idx, repo.auth, repo.config)
+	if err == nil {
+		results, err = pushTrustedIndex(ctx, repo.ref, idx, repo.auth, repo.config, makeIndexOptions(opts...))
+		err = imagePushed(err)
+	}
 	if err != nil {
-		log.Errorf("failed to push image: %s", err)
-		return err
+		repo.config.Log().Errorf("failed to push trusted index: %s", err)
+		return nil, err
 	}
-	return pushTrustedReference(repo.ref, img, repo.auth, repo.config)
+	return results, nil
 }
 
 func (repo *TrustedGcrRepository) Verify() (*client.Target, error) {
-	target, err := getTrustedTarget(repo.ref, repo.auth, repo.config)
+	return repo.VerifyContext(context.Background())
+}
+
+// VerifyContext is like Verify, but aborts the notary round trips when ctx
+// is done.
+func (repo *TrustedGcrRepository) VerifyContext(ctx context.Context) (*client.Target, error) {
+	target, err := getTrustedTarget(ctx, repo.ref, repo.auth, repo.config)
+	if err != nil {
+		repo.config.Log().Errorf("failed to verify repository: %s", err)
+		return nil, err
+	}
+	return &target.Target, nil
+}
+
+// VerifyImage checks that the reference resolves in the registry to the
+// manifest signed for it, failing with ErrDigestMismatch otherwise. It
+// returns the reference to the signed manifest by digest, which is safe to
+// pull, together with the signed target.
+func (repo *TrustedGcrRepository) VerifyImage(ctx context.Context) (name.Digest, *client.Target, error) {
+	digest, target, err := verifyImage(ctx, repo.ref, repo.auth, repo.config)
+	if err != nil {
+		repo.config.Log().Errorf("failed to verify image: %s", err)
+		return name.Digest{}, nil, err
+	}
+	return digest, target, nil
+}
+
+// TrustPull resolves the reference through the trust data and fetches the
+// signed image by digest, so that moving the tag in the registry can never
+// change the image returned. It also returns the signed target and the role
+// it was signed into.
+func (repo *TrustedGcrRepository) TrustPull(ctx context.Context) (v1.Image, *client.TargetWithRole, error) {
+	img, target, err := pullTrustedReference(ctx, repo.ref, repo.auth, repo.config)
+	if err != nil {
+		repo.config.Log().Errorf("failed to pull image: %s", err)
+		return nil, nil, err
+	}
+	return img, target, nil
+}
+
+// SignImage signs img under the tag of the reference, without pushing it.
+// Failures are returned as ErrTrustPush.
+func (repo *TrustedGcrRepository) SignImage(img v1.Image) (*trust.SignResult, error) {
+	return repo.SignImageContext(context.Background(), img)
+}
+
+// SignImageContext is like SignImage, but aborts the notary round trips when
+// ctx is done.
+func (repo *TrustedGcrRepository) SignImageContext(ctx context.Context, img v1.Image) (*trust.SignResult, error) {
+	result, err := signImage(ctx, repo.ref, img, repo.auth, repo.config)
+	if err != nil {
+		repo.config.Log().Errorf("failed to sign image: %s", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// SignImageTags signs img under each of tags in the repository of the
+// reference. All the targets are staged together and published in a single
+// trust data update, so either every tag is signed or none is.
+func (repo *TrustedGcrRepository) SignImageTags(ctx context.Context, img v1.Image, tags []string) ([]*trust.SignResult, error) {
+	results, err := signImageTags(ctx, repo.ref, img, tags, repo.auth, repo.config)
+	if err != nil {
+		repo.config.Log().Errorf("failed to sign image tags: %s", err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// SignIndex signs the multi-platform image index idx under the tag of the
+// reference, without pushing it.
+func (repo *TrustedGcrRepository) SignIndex(ctx context.Context, idx v1.ImageIndex, opts ...IndexOption) ([]*trust.SignResult, error) {
+	results, err := pushTrustedIndex(ctx, repo.ref, idx, repo.auth, repo.config, makeIndexOptions(opts...))
+	if err != nil {
+		repo.config.Log().Errorf("failed to sign index: %s", err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// VerifyIndex checks that the reference resolves in the registry to the
+// image index signed for it. With WithPlatformTargets, each platform
+// specific manifest of the index must also be signed under its platform
+// target. It returns the reference to the index by digest.
+func (repo *TrustedGcrRepository) VerifyIndex(ctx context.Context, opts ...IndexOption) (name.Digest, *client.Target, error) {
+	digest, target, err := verifyIndex(ctx, repo.ref, repo.auth, repo.config, makeIndexOptions(opts...))
+	if err != nil {
+		repo.config.Log().Errorf("failed to verify index: %s", err)
+		return name.Digest{}, nil, err
+	}
+	return digest, target, nil
+}
+
+// RevokeTag removes the signature of tag, or of every tag if it is empty,
+// from all the roles it was signed into.
+func (repo *TrustedGcrRepository) RevokeTag(tag string) (*trust.RevokeResult, error) {
+	return repo.RevokeTagContext(context.Background(), tag)
+}
+
+// RevokeTagContext is like RevokeTag, but aborts the notary round trips when
+// ctx is done.
+func (repo *TrustedGcrRepository) RevokeTagContext(ctx context.Context, tag string) (*trust.RevokeResult, error) {
+	result, err := revokeImage(ctx, repo.ref, tag, repo.auth, repo.config)
 	if err != nil {
-		log.Errorf("failed to verify repository: %s", err)
+		repo.config.Log().Errorf("failed to revoke trusted repository: %s", err)
 		return nil, err
 	}
-	return target, nil
+	return result, nil
+}
+
+// AddDelegation creates the delegation role under targets/, e.g.
+// targets/releases, signed with keys and restricted to paths, and publishes
+// it. An empty path allows the role to sign any target. If the role already
+// exists the keys and paths are added to it. threshold is the number of
+// keys which must sign the role; it can only be set when the role is
+// created, and zero selects one key.
+func (repo *TrustedGcrRepository) AddDelegation(ctx context.Context, role data.RoleName, keys []data.PublicKey, paths []string, threshold int) error {
+	err := addDelegation(ctx, repo.ref, role, keys, paths, threshold, repo.auth, repo.config)
+	if err != nil {
+		repo.config.Log().Errorf("failed to add delegation: %s", err)
+		return err
+	}
+	return nil
+}
+
+// RemoveDelegation removes the delegation role and publishes the change.
+func (repo *TrustedGcrRepository) RemoveDelegation(ctx context.Context, role data.RoleName) error {
+	err := removeDelegation(ctx, repo.ref, role, repo.auth, repo.config)
+	if err != nil {
+		repo.config.Log().Errorf("failed to remove delegation: %s", err)
+		return err
+	}
+	return nil
+}
+
+// AddDelegationKeys adds keys, or certificates, to the delegation role and
+// publishes the change. The role is created without paths if it does not
+// exist.
+func (repo *TrustedGcrRepository) AddDelegationKeys(ctx context.Context, role data.RoleName, keys []data.PublicKey) error {
+	err := addDelegationKeys(ctx, repo.ref, role, keys, repo.auth, repo.config)
+	if err != nil {
+		repo.config.Log().Errorf("failed to add delegation keys: %s", err)
+		return err
+	}
+	return nil
+}
+
+// RemoveDelegationKeys removes the keys with the canonical IDs keyIDs from
+// the delegation role and publishes the change.
+func (repo *TrustedGcrRepository) RemoveDelegationKeys(ctx context.Context, role data.RoleName, keyIDs []string) error {
+	err := removeDelegationKeys(ctx, repo.ref, role, keyIDs, repo.auth, repo.config)
+	if err != nil {
+		repo.config.Log().Errorf("failed to remove delegation keys: %s", err)
+		return err
+	}
+	return nil
+}
+
+// AddDelegationPaths allows the delegation role to sign targets under
+// paths, and publishes the change.
+func (repo *TrustedGcrRepository) AddDelegationPaths(ctx context.Context, role data.RoleName, paths []string) error {
+	err := addDelegationPaths(ctx, repo.ref, role, paths, repo.auth, repo.config)
+	if err != nil {
+		repo.config.Log().Errorf("failed to add delegation paths: %s", err)
+		return err
+	}
+	return nil
+}
+
+// RemoveDelegationPaths removes paths from the delegation role, and
+// publishes the change.
+func (repo *TrustedGcrRepository) RemoveDelegationPaths(ctx context.Context, role data.RoleName, paths []string) error {
+	err := removeDelegationPaths(ctx, repo.ref, role, paths, repo.auth, repo.config)
+	if err != nil {
+		repo.config.Log().Errorf("failed to remove delegation paths: %s", err)
+		return err
+	}
+	return nil
 }
 
-func (repo *TrustedGcrRepository) SignImage(img v1.Image) error {
-	err := signImage(repo.ref, img, repo.auth, repo.config)
+// ClearDelegationPaths removes all the paths of the delegation role, so
+// that it cannot sign any target, and publishes the change.
+func (repo *TrustedGcrRepository) ClearDelegationPaths(ctx context.Context, role data.RoleName) error {
+	err := clearDelegationPaths(ctx, repo.ref, role, repo.auth, repo.config)
 	if err != nil {
-		log.Errorf("failed to sign image: %s", err)
+		repo.config.Log().Errorf("failed to clear delegation paths: %s", err)
 		return err
 	}
 	return nil
 }
 
-func (repo *TrustedGcrRepository) RevokeTag(tag string) error {
-	err := revokeImage(repo.ref, tag, repo.auth, repo.config)
+// ListKeys returns the signing keys of the trust directory for role, or for
+// every role if it is empty, which can sign the repository of the
+// reference.
+func (repo *TrustedGcrRepository) ListKeys(role data.RoleName) ([]trust.KeyInfo, error) {
+	keys, err := listKeys(repo.ref, role, repo.config)
+	if err != nil {
+		repo.config.Log().Errorf("failed to list keys: %s", err)
+		return nil, err
+	}
+	return keys, nil
+}
+
+// GenerateKey creates a signing key for role in the repository of the
+// reference, using algorithm: data.ECDSAKey, data.ED25519Key or
+// data.RSAKey. The key is stored in the trust directory, encrypted with the
+// passphrase given by the passphrase retriever.
+func (repo *TrustedGcrRepository) GenerateKey(role data.RoleName, algorithm string) (data.PublicKey, error) {
+	pubKey, err := generateKey(repo.ref, role, algorithm, repo.config)
+	if err != nil {
+		repo.config.Log().Errorf("failed to generate key: %s", err)
+		return nil, err
+	}
+	return pubKey, nil
+}
+
+// ImportKey stores the PEM encoded private key in the trust directory,
+// for role in the repository of the reference. If role is empty the role
+// header of the PEM block is used.
+func (repo *TrustedGcrRepository) ImportKey(pemBytes []byte, role data.RoleName) (data.PublicKey, error) {
+	pubKey, err := importKey(repo.ref, pemBytes, role, repo.config)
+	if err != nil {
+		repo.config.Log().Errorf("failed to import key: %s", err)
+		return nil, err
+	}
+	return pubKey, nil
+}
+
+// ExportPrivateKey returns the private key keyID in PEM encoded PKCS#8
+// format, encrypted with passphrase.
+func (repo *TrustedGcrRepository) ExportPrivateKey(keyID string, passphrase string) ([]byte, error) {
+	pemBytes, err := exportPrivateKey(keyID, passphrase, repo.config)
+	if err != nil {
+		repo.config.Log().Errorf("failed to export private key: %s", err)
+		return nil, err
+	}
+	return pemBytes, nil
+}
+
+// ExportPublicKey returns the public key keyID in PEM encoded format, as
+// accepted by AddDelegationKeys once parsed with trust.ParsePublicKey.
+func (repo *TrustedGcrRepository) ExportPublicKey(keyID string) ([]byte, error) {
+	pemBytes, err := exportPublicKey(keyID, repo.config)
+	if err != nil {
+		repo.config.Log().Errorf("failed to export public key: %s", err)
+		return nil, err
+	}
+	return pemBytes, nil
+}
+
+// RotateRootKey replaces the root key of the repository of the reference
+// with the local keys keyIDs, or with a newly generated key if keyIDs is
+// empty, and publishes the new root signed by both the old and new keys.
+func (repo *TrustedGcrRepository) RotateRootKey(ctx context.Context, keyIDs []string) error {
+	return repo.rotateKey(ctx, data.CanonicalRootRole, false, keyIDs)
+}
+
+// RotateTargetsKey replaces the targets key of the repository of the
+// reference with the local keys keyIDs, or with a newly generated key if
+// keyIDs is empty, and publishes the change.
+func (repo *TrustedGcrRepository) RotateTargetsKey(ctx context.Context, keyIDs []string) error {
+	return repo.rotateKey(ctx, data.CanonicalTargetsRole, false, keyIDs)
+}
+
+// RotateSnapshotKey replaces the snapshot key of the repository of the
+// reference and publishes the change. If serverManaged, the notary server
+// generates and holds the new key; otherwise the local keys keyIDs, or a
+// newly generated key if keyIDs is empty, are used. This also switches the
+// snapshot key between local and server management.
+func (repo *TrustedGcrRepository) RotateSnapshotKey(ctx context.Context, serverManaged bool, keyIDs []string) error {
+	return repo.rotateKey(ctx, data.CanonicalSnapshotRole, serverManaged, keyIDs)
+}
+
+// RotateTimestampKey asks the notary server, which always holds the
+// timestamp key, to replace it, and publishes the change.
+func (repo *TrustedGcrRepository) RotateTimestampKey(ctx context.Context) error {
+	return repo.rotateKey(ctx, data.CanonicalTimestampRole, true, nil)
+}
+
+func (repo *TrustedGcrRepository) rotateKey(ctx context.Context, role data.RoleName, serverManaged bool, keyIDs []string) error {
+	err := rotateKey(ctx, repo.ref, role, serverManaged, keyIDs, repo.auth, repo.config)
 	if err != nil {
-		log.Errorf("failed to revoke trusted repository: %s", err)
+		repo.config.Log().Errorf("failed to rotate %s key: %s", role, err)
 		return err
 	}
 	return nil
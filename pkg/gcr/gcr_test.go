@@ -0,0 +1,27 @@
+package gcr
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/seeeverything/notary-gcr/trust"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestTrustedGcrRepositoryCache(t *testing.T) {
+	ref, _ := name.ParseReference("example.com/foo/image:1.0", name.WeakValidation)
+	repo, err := NewTrustedGcrRepositoryWithOptions(ref, nil, WithInMemory(nil))
+	assert.NilError(t, err)
+	assert.Check(t, repo.config.RepositoryCache != nil)
+	assert.Check(t, repo.ownsCache)
+	assert.NilError(t, repo.Close())
+
+	// the cache of the caller is kept, and left open
+	cache := trust.NewRepositoryCache(0)
+	repo, err = NewTrustedGcrRepositoryWithOptions(ref, nil, WithConfig(&trust.Config{RepositoryCache: cache}), WithInMemory(nil))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(repo.config.RepositoryCache, cache))
+	assert.Check(t, !repo.ownsCache)
+	assert.NilError(t, repo.Close())
+}
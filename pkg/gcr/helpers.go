@@ -1,6 +1,14 @@
 package gcr
 
 import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/seeeverything/notary-gcr/trust"
 	// "github.com/sirupsen/logrus"
 	"github.com/theupdateframework/notary/client"
 )
@@ -13,3 +21,60 @@ func clearChangeList(notaryRepo client.Repository) error {
 	}
 	return cl.Clear("")
 }
+
+// registryTransport returns the transport used to talk to the registry,
+// bound to ctx. It reuses the connections of config.RepositoryCache.
+func registryTransport(ctx context.Context, config *trust.Config) http.RoundTripper {
+	var rt http.RoundTripper
+	switch {
+	case config.Transport != nil:
+		rt = config.Transport
+	case config.RepositoryCache != nil:
+		rt = config.RepositoryCache.RegistryTransport()
+	default:
+		rt = &http.Transport{
+			MaxIdleConns:       10,
+			IdleConnTimeout:    30 * time.Second,
+			DisableCompression: true,
+		}
+	}
+	return trust.NewContextTransport(ctx, rt)
+}
+
+// digestReference returns the reference to the manifest with digest h in
+// repo.
+func digestReference(repo name.Repository, h v1.Hash) (name.Digest, error) {
+	opts := []name.Option{name.WeakValidation}
+	if repo.Registry.Scheme() == "http" {
+		opts = append(opts, name.Insecure)
+	}
+	return name.NewDigest(repo.Name()+"@"+h.String(), opts...)
+}
+
+// targetDigest returns the sha256 digest signed in target.
+func targetDigest(target *client.Target) (v1.Hash, bool) {
+	sha, ok := target.Hashes["sha256"]
+	if !ok {
+		return v1.Hash{}, false
+	}
+	return v1.Hash{Algorithm: "sha256", Hex: hex.EncodeToString(sha)}, true
+}
+
+// matchTarget checks that the manifest described by desc is the one signed
+// as target.
+func matchTarget(ref name.Reference, target *client.Target, desc v1.Descriptor) error {
+	signed := target.Hashes[desc.Digest.Algorithm]
+	if hex.EncodeToString(signed) == desc.Digest.Hex && target.Length == desc.Size {
+		return nil
+	}
+	mismatch := ErrDigestMismatch{
+		Reference:    ref.String(),
+		SignedLength: target.Length,
+		Remote:       desc.Digest.String(),
+		RemoteLength: desc.Size,
+	}
+	if h, ok := targetDigest(target); ok {
+		mismatch.Signed = h.String()
+	}
+	return mismatch
+}
@@ -0,0 +1,230 @@
+package gcr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/cryptoservice"
+	"github.com/theupdateframework/notary/passphrase"
+	"github.com/theupdateframework/notary/trustmanager"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/signed"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+const testDigest = "sha256:6c3c624b58dbbcd3c0dd82b4c53f04194d1247c6eebdaab7c610cf7d66709b3b"
+
+// newTestRegistry returns an in-memory registry which does not log.
+func newTestRegistry() http.Handler {
+	return registry.New(registry.Logger(log.New(ioutil.Discard, "", 0)))
+}
+
+// testNotaryServer is a notary server keeping the trust data in memory,
+// without validating it. It generates the timestamp keys and signs the
+// timestamps, but does not manage snapshot keys.
+type testNotaryServer struct {
+	*httptest.Server
+	cs signed.CryptoService
+
+	mu            sync.Mutex
+	metadata      map[string][]byte
+	timestampKeys map[string]data.PublicKey
+	publishes     map[string]int
+}
+
+// metadataPath matches the path of the metadata of a GUN, with the role and
+// the optional checksum of the consistent name of the file.
+var metadataPath = regexp.MustCompile(`^/v2/(.+)/_trust/tuf/(?:([^.]+)(?:\.[0-9a-f]{64})?\.(json|key))?$`)
+
+func newTestNotaryServer() *testNotaryServer {
+	s := &testNotaryServer{
+		cs:            cryptoservice.NewCryptoService(trustmanager.NewKeyMemoryStore(passphrase.ConstantRetriever("password"))),
+		metadata:      make(map[string][]byte),
+		timestampKeys: make(map[string]data.PublicKey),
+		publishes:     make(map[string]int),
+	}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *testNotaryServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v2/" {
+		return
+	}
+	m := metadataPath.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	gun, role, ext := m[1], m[2], m[3]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPost && role == "":
+		if err := s.publish(gun, r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	case r.Method == http.MethodGet && ext == "key" && role == data.CanonicalTimestampRole.String():
+		key, err := s.timestampKey(gun)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(key)
+	case r.Method == http.MethodGet && ext == "json":
+		meta, ok := s.metadata[gun+" "+role]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(meta)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *testNotaryServer) timestampKey(gun string) (data.PublicKey, error) {
+	if key, ok := s.timestampKeys[gun]; ok {
+		return key, nil
+	}
+	key, err := s.cs.Create(data.CanonicalTimestampRole, data.GUN(gun), data.ECDSAKey)
+	if err != nil {
+		return nil, err
+	}
+	s.timestampKeys[gun] = key
+	return key, nil
+}
+
+// publish stores the metadata files posted for gun, and signs a new
+// timestamp of the snapshot.
+func (s *testNotaryServer) publish(gun string, r *http.Request) error {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+		meta, err := ioutil.ReadAll(part)
+		if err != nil {
+			return err
+		}
+		s.metadata[gun+" "+part.FileName()] = meta
+	}
+	s.publishes[gun]++
+
+	snapshot, err := data.NewFileMeta(bytes.NewReader(s.metadata[gun+" "+data.CanonicalSnapshotRole.String()]), data.NotaryDefaultHashes...)
+	if err != nil {
+		return err
+	}
+	timestamp := &data.SignedTimestamp{
+		Signatures: []data.Signature{},
+		Signed: data.Timestamp{
+			SignedCommon: data.SignedCommon{
+				Type:    data.TUFTypes[data.CanonicalTimestampRole],
+				Version: s.publishes[gun],
+				Expires: data.DefaultExpires(data.CanonicalTimestampRole),
+			},
+			Meta: data.Files{data.CanonicalSnapshotRole.String(): snapshot},
+		},
+	}
+	ts, err := timestamp.ToSigned()
+	if err != nil {
+		return err
+	}
+	key, err := s.timestampKey(gun)
+	if err != nil {
+		return err
+	}
+	if err := signed.Sign(s.cs, ts, []data.PublicKey{key}, 1, nil); err != nil {
+		return err
+	}
+	s.metadata[gun+" "+data.CanonicalTimestampRole.String()], err = json.Marshal(ts)
+	return err
+}
+
+// Publishes returns the number of publishes of gun.
+func (s *testNotaryServer) Publishes(gun data.GUN) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.publishes[gun.String()]
+}
+
+// Targets returns the targets published in the targets role of gun.
+func (s *testNotaryServer) Targets(t *testing.T, gun data.GUN) data.Files {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var targets data.SignedTargets
+	assert.NilError(t, json.Unmarshal(s.metadata[gun.String()+" "+data.CanonicalTargetsRole.String()], &targets))
+	return targets.Signed.Targets
+}
+
+// SnapshotVersion returns the version of the snapshot published for gun.
+func (s *testNotaryServer) SnapshotVersion(t *testing.T, gun data.GUN) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var snapshot data.SignedSnapshot
+	assert.NilError(t, json.Unmarshal(s.metadata[gun.String()+" "+data.CanonicalSnapshotRole.String()], &snapshot))
+	return snapshot.Signed.Version
+}
+
+// testReference returns the reference to repository in the registry
+// served by server.
+func testReference(t *testing.T, server *httptest.Server, repository string) name.Reference {
+	ref, err := name.ParseReference(strings.TrimPrefix(server.URL, "http://")+"/"+repository, name.WeakValidation)
+	assert.NilError(t, err)
+	return ref
+}
+
+func TestDigestReference(t *testing.T) {
+	h, err := v1.NewHash(testDigest)
+	assert.NilError(t, err)
+
+	ref, _ := name.ParseReference("localhost:5000/foo/image:latest", name.WeakValidation)
+	digest, err := digestReference(ref.Context(), h)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(digest.String(), "localhost:5000/foo/image@"+testDigest))
+	assert.Check(t, is.Equal(digest.Context().Registry.Scheme(), "http"))
+}
+
+func TestMatchTarget(t *testing.T) {
+	h, err := v1.NewHash(testDigest)
+	assert.NilError(t, err)
+	sha, err := hex.DecodeString(h.Hex)
+	assert.NilError(t, err)
+
+	ref, _ := name.ParseReference("dockerhub.com/foo/image:latest", name.WeakValidation)
+	target := &client.Target{Name: "latest", Hashes: data.Hashes{"sha256": sha}, Length: 528}
+	assert.NilError(t, matchTarget(ref, target, v1.Descriptor{Digest: h, Size: 528}))
+
+	err = matchTarget(ref, target, v1.Descriptor{Digest: h, Size: 529})
+	assert.Check(t, is.DeepEqual(err, ErrDigestMismatch{
+		Reference:    ref.String(),
+		Signed:       testDigest,
+		SignedLength: 528,
+		Remote:       testDigest,
+		RemoteLength: 529,
+	}))
+
+	other, err := v1.NewHash("sha256:" + hex.EncodeToString(make([]byte, 32)))
+	assert.NilError(t, err)
+	err = matchTarget(ref, target, v1.Descriptor{Digest: other, Size: 528})
+	assert.Check(t, is.ErrorType(err, ErrDigestMismatch{}))
+}
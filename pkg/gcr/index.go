@@ -0,0 +1,205 @@
+package gcr
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+	"github.com/seeeverything/notary-gcr/trust"
+	"github.com/theupdateframework/notary/client"
+)
+
+// IndexOption configures how image indexes are signed and verified.
+type IndexOption func(*indexOptions)
+
+type indexOptions struct {
+	platformTargets bool
+}
+
+// WithPlatformTargets also signs, or verifies, each platform specific
+// manifest of the index under a target named after the tag and the
+// platform, e.g. "1.0-linux-arm64-v8" for the tag "1.0". Manifests for an
+// unknown platform, such as attestation manifests, are left out.
+func WithPlatformTargets() IndexOption {
+	return func(o *indexOptions) {
+		o.platformTargets = true
+	}
+}
+
+func makeIndexOptions(opts ...IndexOption) indexOptions {
+	o := indexOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// platformTargetName returns the name of the target signing the manifest
+// for platform p in the index tagged tag.
+func platformTargetName(tag string, p *v1.Platform) string {
+	parts := []string{tag, p.OS, p.Architecture}
+	if p.Variant != "" {
+		parts = append(parts, p.Variant)
+	}
+	if p.OSVersion != "" {
+		parts = append(parts, p.OSVersion)
+	}
+	return strings.Join(parts, "-")
+}
+
+// platformManifests returns the platform specific manifests of the index
+// tagged tag, and the names of their targets. Manifests for an unknown
+// platform are skipped. Two manifests with the same target name, which
+// would overwrite each other's signature, are an error.
+func platformManifests(tag string, manifest *v1.IndexManifest) ([]string, []v1.Descriptor, error) {
+	var (
+		names    []string
+		children []v1.Descriptor
+	)
+	digests := make(map[string]v1.Hash)
+	for _, child := range manifest.Manifests {
+		p := child.Platform
+		if p == nil || p.OS == "unknown" || p.Architecture == "unknown" {
+			continue
+		}
+		targetName := platformTargetName(tag, p)
+		if digest, ok := digests[targetName]; ok {
+			return nil, nil, errors.Errorf("manifests %s and %s of the index both have the platform target %s", digest, child.Digest, targetName)
+		}
+		digests[targetName] = child.Digest
+		names = append(names, targetName)
+		children = append(children, child)
+	}
+	return names, children, nil
+}
+
+func pushIndex(ctx context.Context, ref name.Reference, idx v1.ImageIndex, auth authn.Authenticator, config *trust.Config) error {
+	err := remote.WriteIndex(ref, idx, remote.WithAuth(auth), remote.WithTransport(registryTransport(ctx, config)))
+	if err != nil {
+		return ErrTrustPush{Reference: ref.String(), Phase: PhaseImagePush, Err: err}
+	}
+	return nil
+}
+
+// indexTargets returns the targets signing idx under the tag of ref and,
+// if requested, its platform specific manifests.
+func indexTargets(ref name.Reference, idx v1.ImageIndex, o indexOptions) ([]*client.Target, error) {
+	target, err := newTarget(ref.Identifier(), idx)
+	if err != nil {
+		return nil, err
+	}
+	targets := []*client.Target{target}
+	if !o.platformTargets {
+		return targets, nil
+	}
+
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get index manifest")
+	}
+	names, children, err := platformManifests(ref.Identifier(), manifest)
+	if err != nil {
+		return nil, err
+	}
+	for i, child := range children {
+		target, err := newTargetFromDescriptor(names[i], child)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+func pushTrustedIndex(ctx context.Context, ref name.Reference, idx v1.ImageIndex, auth authn.Authenticator, config *trust.Config, o indexOptions) ([]*trust.SignResult, error) {
+	targets, err := indexTargets(ref, idx, o)
+	if err != nil {
+		config.Log().Errorf("failed to build targets: %s", err)
+		return nil, ErrTrustPush{Reference: ref.Context().Name(), Phase: PhaseStage, Err: err}
+	}
+	return publishTargets(ctx, ref, auth, config, targets...)
+}
+
+// verifyIndex checks that ref resolves in the registry to the index signed
+// for it and, if requested, that each of its platform specific manifests is
+// signed and available. It returns the reference to the index by digest.
+func verifyIndex(ctx context.Context, ref name.Reference, auth authn.Authenticator, config *trust.Config, o indexOptions) (name.Digest, *client.Target, error) {
+	repoInfo := ref.Context().Registry
+	notaryRepo, release, err := trust.AcquireNotaryRepository(ctx, ref, auth, &repoInfo, config, true)
+	if err != nil {
+		return name.Digest{}, nil, errors.Wrap(err, "error establishing connection to trust repository")
+	}
+	defer release()
+	tag, err := name.NewTag(ref.String(), name.StrictValidation)
+	if err != nil {
+		return name.Digest{}, nil, errors.Wrap(err, "couldn't parse tag from repository name")
+	}
+	t, err := trustedTarget(notaryRepo, ref, tag.Identifier())
+	if err != nil {
+		return name.Digest{}, nil, err
+	}
+
+	transport := registryTransport(ctx, config)
+	desc, err := remote.Get(ref, remote.WithAuth(auth), remote.WithTransport(transport))
+	if err != nil {
+		return name.Digest{}, nil, errors.Wrap(err, "couldn't fetch remote manifest")
+	}
+	if err := matchTarget(ref, &t.Target, desc.Descriptor); err != nil {
+		return name.Digest{}, nil, err
+	}
+	if desc.MediaType != types.DockerManifestList && desc.MediaType != types.OCIImageIndex {
+		return name.Digest{}, nil, errors.Errorf("%s is not an image index: %s", ref, desc.MediaType)
+	}
+
+	if o.platformTargets {
+		manifest, err := v1.ParseIndexManifest(bytes.NewReader(desc.Manifest))
+		if err != nil {
+			return name.Digest{}, nil, errors.Wrap(err, "couldn't parse index manifest")
+		}
+		names, children, err := platformManifests(tag.Identifier(), manifest)
+		if err != nil {
+			return name.Digest{}, nil, err
+		}
+		for i, child := range children {
+			if err := verifyIndexChild(notaryRepo, ref, names[i], child, auth, transport); err != nil {
+				return name.Digest{}, nil, err
+			}
+		}
+	}
+
+	digest, err := digestReference(ref.Context(), desc.Digest)
+	if err != nil {
+		return name.Digest{}, nil, err
+	}
+	config.Log().Debugf("%s verified as %s", ref, digest)
+	return digest, &t.Target, nil
+}
+
+// verifyIndexChild checks that the platform specific manifest child of the
+// index of ref is signed under targetName, and available in the registry.
+func verifyIndexChild(notaryRepo client.Repository, ref name.Reference, targetName string, child v1.Descriptor, auth authn.Authenticator, transport http.RoundTripper) error {
+	childRef, err := digestReference(ref.Context(), child.Digest)
+	if err != nil {
+		return err
+	}
+	t, err := trustedTarget(notaryRepo, ref, targetName)
+	if err != nil {
+		return err
+	}
+	if err := matchTarget(childRef, &t.Target, child); err != nil {
+		return err
+	}
+	// Fetching by digest makes the registry client check the manifest
+	// content against the digest listed in the index.
+	if _, err := remote.Get(childRef, remote.WithAuth(auth), remote.WithTransport(transport)); err != nil {
+		return errors.Wrapf(err, "couldn't fetch %s", childRef)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package gcr
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestPlatformTargetName(t *testing.T) {
+	assert.Check(t, is.Equal(platformTargetName("1.0", &v1.Platform{OS: "linux", Architecture: "amd64"}), "1.0-linux-amd64"))
+	assert.Check(t, is.Equal(platformTargetName("1.0", &v1.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}), "1.0-linux-arm64-v8"))
+	assert.Check(t, is.Equal(platformTargetName("1.0", &v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.1234"}), "1.0-windows-amd64-10.0.17763.1234"))
+}
+
+func TestIndexTargets(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	assert.NilError(t, err)
+	imgDigest, err := img.Digest()
+	assert.NilError(t, err)
+	idx := mutate.AppendManifests(empty.Index,
+		mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "arm64"}}},
+		mutate.IndexAddendum{Add: img},
+	)
+	idxDigest, err := idx.Digest()
+	assert.NilError(t, err)
+
+	ref, _ := name.ParseReference("dockerhub.com/foo/image:1.0", name.WeakValidation)
+	targets, err := indexTargets(ref, idx, makeIndexOptions())
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(targets, 1))
+	assert.Check(t, is.Equal(targets[0].Name, "1.0"))
+	assert.NilError(t, matchTarget(ref, targets[0], v1.Descriptor{Digest: idxDigest, Size: targets[0].Length}))
+
+	targets, err = indexTargets(ref, idx, makeIndexOptions(WithPlatformTargets()))
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(targets, 2))
+	assert.Check(t, is.Equal(targets[1].Name, "1.0-linux-arm64"))
+	assert.NilError(t, matchTarget(ref, targets[1], v1.Descriptor{Digest: imgDigest, Size: targets[1].Length}))
+}
+
+func TestPlatformManifests(t *testing.T) {
+	digest := func(c byte) v1.Hash {
+		return v1.Hash{Algorithm: "sha256", Hex: strings.Repeat(string(c), 64)}
+	}
+	manifest := &v1.IndexManifest{Manifests: []v1.Descriptor{
+		{Digest: digest('a'), Platform: &v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.1234"}},
+		{Digest: digest('b'), Platform: &v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.20348.643"}},
+		// attestation manifests
+		{Digest: digest('c'), Platform: &v1.Platform{OS: "unknown", Architecture: "unknown"}},
+		{Digest: digest('d'), Platform: &v1.Platform{OS: "unknown", Architecture: "unknown"}},
+		{Digest: digest('e')},
+	}}
+	names, children, err := platformManifests("1.0", manifest)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(names, []string{"1.0-windows-amd64-10.0.17763.1234", "1.0-windows-amd64-10.0.20348.643"}))
+	assert.Check(t, is.Len(children, 2))
+
+	manifest.Manifests = append(manifest.Manifests, v1.Descriptor{Digest: digest('f'), Platform: &v1.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.1234"}})
+	_, _, err = platformManifests("1.0", manifest)
+	assert.Check(t, is.ErrorContains(err, "both have the platform target 1.0-windows-amd64-10.0.17763.1234"))
+}
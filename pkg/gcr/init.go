@@ -0,0 +1,163 @@
+package gcr
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+	"github.com/seeeverything/notary-gcr/trust"
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// InitOption configures how the trust data of a repository is initialized.
+type InitOption func(*initOptions)
+
+type initOptions struct {
+	rootKeyIDs    []string
+	rootThreshold int
+	keyAlgorithm  string
+	localSnapshot bool
+}
+
+// WithRootKeys signs the root role with the existing root keys keyIDs,
+// instead of the first root key of the trust directory or a new one.
+func WithRootKeys(keyIDs ...string) InitOption {
+	return func(o *initOptions) {
+		o.rootKeyIDs = keyIDs
+	}
+}
+
+// WithRootThreshold sets the number of root keys which must sign the root
+// role. Notary only supports a threshold of 1.
+func WithRootThreshold(threshold int) InitOption {
+	return func(o *initOptions) {
+		o.rootThreshold = threshold
+	}
+}
+
+// WithKeyAlgorithm generates the keys of the repository with algorithm:
+// data.ECDSAKey, data.ED25519Key or data.RSAKey. Root keys cannot be
+// ed25519 keys, and remain ECDSA keys with data.ED25519Key.
+func WithKeyAlgorithm(algorithm string) InitOption {
+	return func(o *initOptions) {
+		o.keyAlgorithm = algorithm
+	}
+}
+
+// WithLocalSnapshotKey signs the snapshot role with a key of the trust
+// directory, instead of letting the notary server manage it.
+func WithLocalSnapshotKey() InitOption {
+	return func(o *initOptions) {
+		o.localSnapshot = true
+	}
+}
+
+func makeInitOptions(opts ...InitOption) initOptions {
+	o := initOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// validate checks the options which notary does not support, before any
+// key is generated.
+func (o initOptions) validate() error {
+	if o.rootThreshold > notary.MinThreshold {
+		return errors.Errorf("root threshold %d is not supported, notary only supports a threshold of %d", o.rootThreshold, notary.MinThreshold)
+	}
+	switch o.keyAlgorithm {
+	case "", data.ECDSAKey, data.ED25519Key, data.RSAKey:
+	default:
+		return errors.Errorf("key algorithm %s is not supported", o.keyAlgorithm)
+	}
+	return nil
+}
+
+// initializeRepository creates the trust data of notaryRepo locally; it is
+// pushed to the server on the next publish.
+func initializeRepository(notaryRepo client.Repository, o initOptions, config *trust.Config) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+	cs := notaryRepo.GetCryptoService()
+	keys := cs.ListKeys(data.CanonicalRootRole)
+	rootKeyIDs := o.rootKeyIDs
+	for _, keyID := range rootKeyIDs {
+		if !containsKey(keys, keyID) {
+			return errors.Errorf("root key %s not found", keyID)
+		}
+	}
+	if len(rootKeyIDs) == 0 {
+		// always select the first root key
+		if len(keys) > 0 {
+			sort.Strings(keys)
+			rootKeyIDs = keys[:1]
+		} else {
+			rootPublicKey, err := cs.Create(data.CanonicalRootRole, "", data.ECDSAKey)
+			if err != nil {
+				return errors.Wrap(err, "failed to generate root key")
+			}
+			rootKeyIDs = []string{rootPublicKey.ID()}
+		}
+	}
+
+	if o.localSnapshot {
+		return notaryRepo.Initialize(rootKeyIDs)
+	}
+	// Initialize the notary repository with a remotely managed snapshot key
+	return notaryRepo.Initialize(rootKeyIDs, data.CanonicalSnapshotRole)
+}
+
+func containsKey(keyIDs []string, keyID string) bool {
+	for _, id := range keyIDs {
+		if id == keyID {
+			return true
+		}
+	}
+	return false
+}
+
+// initialize creates and publishes the trust data of ref's repository,
+// which must not exist yet.
+func initialize(ctx context.Context, ref name.Reference, auth authn.Authenticator, config *trust.Config, o initOptions) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+	if o.keyAlgorithm != "" {
+		c := *config
+		c.KeyAlgorithm = o.keyAlgorithm
+		// the cached repositories generate keys with the configured algorithm
+		c.RepositoryCache = nil
+		config = &c
+	}
+
+	repoInfo := ref.Context().Registry
+	notaryRepo, release, err := trust.AcquireNotaryRepository(ctx, ref, auth, &repoInfo, config, false)
+	if err != nil {
+		return errors.Wrap(err, "error establishing connection to trust repository")
+	}
+	defer release()
+
+	_, err = notaryRepo.ListTargets()
+	switch err.(type) {
+	case client.ErrRepoNotInitialized, client.ErrRepositoryNotExist:
+	case nil:
+		return errors.Errorf("trust data for %s is already initialized", ref.Context().Name())
+	default:
+		return trust.NotaryError(ref.Context().Name(), err)
+	}
+
+	if err := initializeRepository(notaryRepo, o, config); err != nil {
+		return trust.NotaryError(ref.Context().Name(), err)
+	}
+	if err := notaryRepo.Publish(); err != nil {
+		return trust.NotaryError(ref.Context().Name(), err)
+	}
+	config.Log().Infof("Finished initializing %s\n", ref.Context().Name())
+	return nil
+}
@@ -0,0 +1,58 @@
+package gcr
+
+import (
+	"testing"
+
+	"github.com/seeeverything/notary-gcr/trust"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/signed"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestMakeInitOptions(t *testing.T) {
+	o := makeInitOptions()
+	assert.Check(t, is.Len(o.rootKeyIDs, 0))
+	assert.Check(t, !o.localSnapshot)
+
+	o = makeInitOptions(
+		WithRootKeys("abc", "def"),
+		WithRootThreshold(1),
+		WithKeyAlgorithm(data.RSAKey),
+		WithLocalSnapshotKey(),
+	)
+	assert.Check(t, is.DeepEqual(o.rootKeyIDs, []string{"abc", "def"}))
+	assert.Check(t, is.Equal(o.rootThreshold, 1))
+	assert.Check(t, is.Equal(o.keyAlgorithm, data.RSAKey))
+	assert.Check(t, o.localSnapshot)
+}
+
+// cryptoRepository is a repository with only a crypto service.
+type cryptoRepository struct {
+	client.Repository
+	cs signed.CryptoService
+}
+
+func (r cryptoRepository) GetCryptoService() signed.CryptoService {
+	return r.cs
+}
+
+func TestInitializeRepositoryRootThreshold(t *testing.T) {
+	err := initializeRepository(nil, initOptions{rootKeyIDs: []string{"abc"}, rootThreshold: 2}, &trust.Config{})
+	assert.Error(t, err, "root threshold 2 is not supported, notary only supports a threshold of 1")
+}
+
+func TestInitializeRepositoryInvalidOptions(t *testing.T) {
+	cs := trust.NewMemoryCryptoService(&trust.Config{InMemory: true})
+	repo := cryptoRepository{cs: cs}
+	for _, o := range []initOptions{
+		{rootThreshold: 2},
+		{keyAlgorithm: "dsa"},
+		{rootKeyIDs: []string{"abc"}},
+	} {
+		assert.Check(t, initializeRepository(repo, o, &trust.Config{}) != nil, "%+v", o)
+	}
+	// no root key was generated for nothing
+	assert.Check(t, is.Len(cs.ListKeys(data.CanonicalRootRole), 0))
+}
@@ -0,0 +1,57 @@
+package gcr
+
+import (
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/seeeverything/notary-gcr/trust"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+func listKeys(ref name.Reference, role data.RoleName, config *trust.Config) ([]trust.KeyInfo, error) {
+	cs, err := trust.GetCryptoService(config)
+	if err != nil {
+		return nil, err
+	}
+	return trust.ListKeys(cs, role, config.GUN(ref)), nil
+}
+
+func generateKey(ref name.Reference, role data.RoleName, algorithm string, config *trust.Config) (data.PublicKey, error) {
+	cs, err := trust.GetCryptoService(config)
+	if err != nil {
+		return nil, err
+	}
+	pubKey, err := trust.GenerateKey(cs, role, config.GUN(ref), algorithm)
+	if err != nil {
+		return nil, err
+	}
+	config.Log().Infof("Generated %s %s key %s\n", algorithm, role, pubKey.ID())
+	return pubKey, nil
+}
+
+func importKey(ref name.Reference, pemBytes []byte, role data.RoleName, config *trust.Config) (data.PublicKey, error) {
+	cs, err := trust.GetCryptoService(config)
+	if err != nil {
+		return nil, err
+	}
+	pubKey, err := trust.ImportKey(cs, pemBytes, role, config.GUN(ref), config)
+	if err != nil {
+		return nil, err
+	}
+	config.Log().Infof("Imported key %s\n", pubKey.ID())
+	return pubKey, nil
+}
+
+func exportPrivateKey(keyID string, passphrase string, config *trust.Config) ([]byte, error) {
+	cs, err := trust.GetCryptoService(config)
+	if err != nil {
+		return nil, err
+	}
+	return trust.ExportPrivateKey(cs, keyID, passphrase)
+}
+
+func exportPublicKey(keyID string, config *trust.Config) ([]byte, error) {
+	cs, err := trust.GetCryptoService(config)
+	if err != nil {
+		return nil, err
+	}
+	return trust.ExportPublicKey(cs, keyID)
+}
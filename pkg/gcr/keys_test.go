@@ -0,0 +1,23 @@
+package gcr
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/theupdateframework/notary/tuf/data"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestInMemoryKeys(t *testing.T) {
+	ref, _ := name.ParseReference("example.com/foo:latest", name.WeakValidation)
+	repo, err := NewTrustedGcrRepositoryWithOptions(ref, nil, WithInMemory(nil), WithPassphrases("root", "repository"))
+	assert.NilError(t, err)
+
+	key, err := repo.GenerateKey(data.CanonicalTargetsRole, data.ECDSAKey)
+	assert.NilError(t, err)
+	keys, err := repo.ListKeys("")
+	assert.NilError(t, err)
+	assert.Assert(t, is.Len(keys, 1))
+	assert.Check(t, is.Equal(keys[0].ID, key.ID()))
+}
@@ -1,39 +1,25 @@
 package gcr
 
 import (
-	"encoding/hex"
-	"fmt"
+	"context"
 
 	"github.com/seeeverything/notary-gcr/trust"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
-	log "github.com/sirupsen/logrus"
 	"github.com/theupdateframework/notary/client"
 )
 
-func listTargets(ref name.Reference, auth authn.Authenticator, config *trust.Config) ([]*client.Target, error) {
+func listTargets(ctx context.Context, ref name.Reference, auth authn.Authenticator, config *trust.Config) ([]*client.TargetWithRole, error) {
 	registry := ref.Context().Registry
-	repo, err := trust.GetNotaryRepository(ref, auth, &registry, config)
+	repo, release, err := trust.AcquireNotaryRepository(ctx, ref, auth, &registry, config, true)
 	if err != nil {
-		log.Errorf("failed to get notary repository %s", err)
+		config.Log().Errorf("failed to get notary repository %s", err)
 		return nil, err
 	}
-	rawTargets, err := repo.ListTargets()
+	defer release()
+	targets, err := repo.ListTargets()
 	if err != nil {
-		log.Errorf("failed to get notary repository %s", err)
-		return nil, err
-	}
-
-	var targets []*client.Target
-	for _, t := range rawTargets {
-		targets = append(targets, &t.Target)
-		log.Infof(
-			"%s: %s, %s, %s\n",
-			t.Name,
-			hex.EncodeToString(t.Hashes["sha256"]),
-			fmt.Sprintf("%d", t.Length),
-			t.Role,
-		)
+		return nil, trust.NotaryError(ref.Context().Name(), err)
 	}
 	return targets, nil
 }
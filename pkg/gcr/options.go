@@ -0,0 +1,189 @@
+package gcr
+
+import (
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/seeeverything/notary-gcr/trust"
+	log "github.com/sirupsen/logrus"
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/tuf/signed"
+)
+
+// Option configures a TrustedGcrRepository built by
+// NewTrustedGcrRepositoryWithOptions, or the verifications of VerifyImages.
+type Option func(*options)
+
+type options struct {
+	config      trust.Config
+	parallelism int
+}
+
+func makeOptions(opts ...Option) options {
+	o := options{parallelism: DefaultParallelism}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.config.RootPath == "" && !o.config.InMemory {
+		o.config.RootPath = trust.DefaultConfigDir()
+	}
+	return o
+}
+
+// WithConfig uses a copy of config as the base configuration. Options
+// applied after it override the corresponding fields.
+func WithConfig(config *trust.Config) Option {
+	return func(o *options) {
+		o.config = *config
+	}
+}
+
+// WithServerURL sets the URL of the notary server.
+func WithServerURL(serverURL string) Option {
+	return func(o *options) {
+		o.config.ServerUrl = serverURL
+	}
+}
+
+// WithTrustDir sets the directory holding the trust data, keys and TLS
+// certificates.
+func WithTrustDir(dir string) Option {
+	return func(o *options) {
+		o.config.RootPath = dir
+	}
+}
+
+// WithPassphrases sets the passphrases of the root key and of the
+// repository keys.
+func WithPassphrases(rootPassphrase, repositoryPassphrase string) Option {
+	return func(o *options) {
+		o.config.RootPassphrase = rootPassphrase
+		o.config.RepositoryPassphrase = repositoryPassphrase
+	}
+}
+
+// WithPassphraseRetriever sets the retriever used to unlock signing keys.
+func WithPassphraseRetriever(retriever notary.PassRetriever) Option {
+	return func(o *options) {
+		o.config.PassRetriever = retriever
+	}
+}
+
+// WithNonInteractive makes operations fail with trust.ErrPassphraseRequired
+// instead of prompting on stdin when a passphrase is missing.
+func WithNonInteractive() Option {
+	return func(o *options) {
+		o.config.NonInteractive = true
+	}
+}
+
+// WithReadOnly authenticates to the notary server with pull scope only, for
+// credentials which cannot push. Signing, revoking and managing the trust
+// data then fail with trust.ErrReadOnly.
+func WithReadOnly() Option {
+	return func(o *options) {
+		o.config.ReadOnly = true
+	}
+}
+
+// WithGUNMapping rewrites GUN prefixes, e.g. to sign and verify the images
+// of a registry mirror in the trust collection of the registry it mirrors.
+func WithGUNMapping(mapping map[string]string) Option {
+	return func(o *options) {
+		o.config.GUNMapping = mapping
+	}
+}
+
+// WithNotaryAuth authenticates to the notary server with auth instead of
+// the registry authenticator.
+func WithNotaryAuth(auth authn.Authenticator) Option {
+	return func(o *options) {
+		o.config.NotaryAuth = auth
+	}
+}
+
+// WithKeychain resolves the credentials of the registry, when no
+// authenticator is given, and of the notary server, when WithNotaryAuth is
+// not used, with keychain.
+func WithKeychain(keychain authn.Keychain) Option {
+	return func(o *options) {
+		o.config.Keychain = keychain
+	}
+}
+
+// WithTLSConfig sets the TLS policy for the notary server.
+func WithTLSConfig(tls trust.TLSConfig) Option {
+	return func(o *options) {
+		o.config.TLS = tls
+	}
+}
+
+// WithTrustPinning pins the root of trust of repositories.
+func WithTrustPinning(pinning trust.TrustPinningConfig) Option {
+	return func(o *options) {
+		o.config.TrustPinning = pinning
+	}
+}
+
+// WithAutoInitialize initializes the trust data of the repository when an
+// image is first signed into it, as Initialize would with its defaults,
+// instead of failing.
+func WithAutoInitialize() Option {
+	return func(o *options) {
+		o.config.AutoInitialize = true
+	}
+}
+
+// WithInMemory keeps the trust data and the changelist in memory, so that
+// no trust directory is needed. cache, if not nil, caches the trust data
+// across operations; it may be shared by several repositories. Unless
+// WithCryptoService is used, keys are kept in memory for the lifetime of
+// the repository.
+func WithInMemory(cache *trust.MemoryCache) Option {
+	return func(o *options) {
+		o.config.InMemory = true
+		o.config.MemoryCache = cache
+	}
+}
+
+// WithCryptoService signs with the keys of cs instead of the keys of the
+// trust directory.
+func WithCryptoService(cs signed.CryptoService) Option {
+	return func(o *options) {
+		o.config.CryptoService = cs
+	}
+}
+
+// WithTargetCache caches the targets verified for tags in cache, which
+// can be shared between repositories. Signing and revoking through them
+// invalidate the affected tags.
+func WithTargetCache(cache *trust.TargetCache) Option {
+	return func(o *options) {
+		o.config.TargetCache = cache
+	}
+}
+
+// WithTransport sets the base transport used to reach the registry and
+// the notary server.
+func WithTransport(t http.RoundTripper) Option {
+	return func(o *options) {
+		o.config.Transport = t
+	}
+}
+
+// WithLogger sets the logger operations report to.
+func WithLogger(logger log.FieldLogger) Option {
+	return func(o *options) {
+		o.config.Logger = logger
+	}
+}
+
+// WithParallelism sets the number of references VerifyImages verifies at
+// the same time.
+func WithParallelism(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.parallelism = n
+		}
+	}
+}
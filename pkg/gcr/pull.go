@@ -0,0 +1,47 @@
+package gcr
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+	"github.com/seeeverything/notary-gcr/trust"
+	"github.com/theupdateframework/notary/client"
+)
+
+// pullTrustedReference fetches the image signed for ref by its digest.
+func pullTrustedReference(ctx context.Context, ref name.Reference, auth authn.Authenticator, config *trust.Config) (v1.Image, *client.TargetWithRole, error) {
+	target, err := getTrustedTarget(ctx, ref, auth, config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h, ok := targetDigest(&target.Target)
+	if !ok {
+		return nil, nil, errors.Errorf("no sha256 digest signed for %s", ref)
+	}
+	digest, err := digestReference(ref.Context(), h)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Fetching by digest makes the registry client check the manifest
+	// content against the signed digest.
+	desc, err := remote.Get(digest, remote.WithAuth(auth), remote.WithTransport(registryTransport(ctx, config)))
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "couldn't fetch %s", digest)
+	}
+	if err := matchTarget(ref, &target.Target, desc.Descriptor); err != nil {
+		return nil, nil, err
+	}
+
+	img, err := desc.Image()
+	if err != nil {
+		return nil, nil, err
+	}
+	config.Log().Debugf("pulled %s as %s", ref, digest)
+	return img, target, nil
+}
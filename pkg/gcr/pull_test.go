@@ -0,0 +1,96 @@
+package gcr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/seeeverything/notary-gcr/trust"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+// trustPull pulls ref with target signed for it.
+func trustPull(t *testing.T, ref name.Reference, target *client.Target) (v1.Image, error) {
+	targets := trust.NewTargetCache(time.Hour, time.Hour)
+	targets.Add(data.GUN(ref.Context().Name()), ref.Identifier(), &client.TargetWithRole{Target: *target, Role: trust.ReleasesRole})
+	repo, err := NewTrustedGcrRepositoryWithOptions(ref, nil, WithInMemory(nil), WithTargetCache(targets))
+	assert.NilError(t, err)
+	defer repo.Close()
+	img, pulled, err := repo.TrustPull(context.Background())
+	if err == nil {
+		assert.Check(t, is.DeepEqual(pulled.Target, *target))
+	}
+	return img, err
+}
+
+func TestTrustPull(t *testing.T) {
+	server := httptest.NewServer(newTestRegistry())
+	defer server.Close()
+	ref := testReference(t, server, "foo/image:1.0")
+
+	signed, err := random.Image(1024, 1)
+	assert.NilError(t, err)
+	assert.NilError(t, remote.Write(ref, signed))
+	target, err := newTarget("1.0", signed)
+	assert.NilError(t, err)
+	signedDigest, err := signed.Digest()
+	assert.NilError(t, err)
+
+	img, err := trustPull(t, ref, target)
+	assert.NilError(t, err)
+	digest, err := img.Digest()
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(digest, signedDigest))
+
+	// the signed image is pulled even once the tag was moved
+	moved, err := random.Image(1024, 1)
+	assert.NilError(t, err)
+	assert.NilError(t, remote.Write(ref, moved))
+	img, err = trustPull(t, ref, target)
+	assert.NilError(t, err)
+	digest, err = img.Digest()
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(digest, signedDigest))
+}
+
+func TestTrustPullDigestMismatch(t *testing.T) {
+	signed, err := random.Image(1024, 1)
+	assert.NilError(t, err)
+	signedDigest, err := signed.Digest()
+	assert.NilError(t, err)
+	tampered, err := random.Image(1024, 1)
+	assert.NilError(t, err)
+	tamperedManifest, err := tampered.RawManifest()
+	assert.NilError(t, err)
+	mediaType, err := tampered.MediaType()
+	assert.NilError(t, err)
+
+	// the registry serves another manifest for the signed digest
+	reg := newTestRegistry()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/manifests/"+signedDigest.String()) {
+			w.Header().Set("Content-Type", string(mediaType))
+			w.Write(tamperedManifest)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	ref := testReference(t, server, "foo/image:1.0")
+	assert.NilError(t, remote.Write(ref, tampered))
+
+	target, err := newTarget("1.0", signed)
+	assert.NilError(t, err)
+	_, err = trustPull(t, ref, target)
+	assert.Check(t, is.ErrorContains(err, "does not match requested digest"))
+}
@@ -1,120 +1,181 @@
 package gcr
 
 import (
+	"context"
 	"encoding/hex"
-	"net/http"
-	"sort"
-	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/pkg/errors"
-	log "github.com/sirupsen/logrus"
 
 	"github.com/seeeverything/notary-gcr/trust"
 	"github.com/theupdateframework/notary/client"
 	"github.com/theupdateframework/notary/tuf/data"
 )
 
-func pushImage(ref name.Reference, img v1.Image, auth authn.Authenticator) error {
-	defaultRoundTripper := &http.Transport{
-		MaxIdleConns:       10,
-		IdleConnTimeout:    30 * time.Second,
-		DisableCompression: true,
-	}
-	err := remote.Write(ref, img, remote.WithAuth(auth), remote.WithTransport(defaultRoundTripper))
+func pushImage(ctx context.Context, ref name.Reference, img v1.Image, auth authn.Authenticator, config *trust.Config) error {
+	err := remote.Write(ref, img, remote.WithAuth(auth), remote.WithTransport(registryTransport(ctx, config)))
 	if err != nil {
-		log.Errorf("failed to push image: %s", err)
-		return err
+		return ErrTrustPush{Reference: ref.String(), Phase: PhaseImagePush, Err: err}
 	}
 	return nil
 }
 
-func pushTrustedReference(ref name.Reference, img v1.Image, auth authn.Authenticator, config *trust.Config) error {
+// manifest is implemented by v1.Image and v1.ImageIndex.
+type manifest interface {
+	Digest() (v1.Hash, error)
+	RawManifest() ([]byte, error)
+}
+
+// newTarget returns the target signing m under name.
+func newTarget(name string, m manifest) (*client.Target, error) {
+	digest, err := m.Digest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get manifest digest")
+	}
+	raw, err := m.RawManifest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get raw manifest")
+	}
+	return newTargetFromDescriptor(name, v1.Descriptor{Digest: digest, Size: int64(len(raw))})
+}
+
+// newTargetFromDescriptor returns the target signing the manifest
+// described by desc under name.
+func newTargetFromDescriptor(name string, desc v1.Descriptor) (*client.Target, error) {
+	h, err := hex.DecodeString(desc.Digest.Hex)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode digest")
+	}
+	return &client.Target{
+		Name:   name,
+		Hashes: data.Hashes{desc.Digest.Algorithm: h},
+		Length: desc.Size,
+	}, nil
+}
+
+func pushTrustedReference(ctx context.Context, ref name.Reference, img v1.Image, auth authn.Authenticator, config *trust.Config) (*trust.SignResult, error) {
 	// If it is a trusted push we would like to find the target entry which match the
 	// tag provided in the function and then do an AddTarget later.
-	target := &client.Target{}
-
-	digest, err := img.Digest()
+	target, err := newTarget(ref.Identifier(), img)
 	if err != nil {
-		log.Errorf("failed to get img.Digest: %s", err)
-		return err
+		config.Log().Errorf("failed to build target: %s", err)
+		return nil, ErrTrustPush{Reference: ref.Context().Name(), Phase: PhaseStage, Err: err}
 	}
-	h, err := hex.DecodeString(digest.Hex)
+	results, err := publishTargets(ctx, ref, auth, config, target)
 	if err != nil {
-		log.Errorf("failed to decode digest.Hex: %s", err)
-		return err
+		return nil, err
 	}
-	target.Name = ref.Identifier()
-	target.Hashes = data.Hashes{digest.Algorithm: h}
-	manifest, _ := img.RawManifest()
-	pushResultSize := len(manifest)
-	target.Length = int64(pushResultSize)
+	return results[0], nil
+}
 
-	if target == nil {
-		return errors.Errorf("no targets found, please provide a specific tag in order to sign it")
+// publishTargets signs targets into the trust data of ref's repository,
+// initializing it if needed, and publishes them in a single update. Failures
+// are returned as ErrTrustPush, naming the phase which failed.
+func publishTargets(ctx context.Context, ref name.Reference, auth authn.Authenticator, config *trust.Config, targets ...*client.Target) ([]*trust.SignResult, error) {
+	gun := ref.Context().Name()
+	if len(targets) == 0 {
+		return nil, ErrTrustPush{Reference: gun, Phase: PhaseStage, Err: errors.Errorf("no targets found, please provide a specific tag in order to sign it")}
 	}
 
 	repoInfo := ref.Context().Registry
-	repo, err := trust.GetNotaryRepository(ref, auth, &repoInfo, config)
+	repo, release, err := trust.AcquireNotaryRepository(ctx, ref, auth, &repoInfo, config, false)
 	if err != nil {
-		log.Errorf("failed to get notary repository %s", err)
-		return err
+		return nil, ErrTrustPush{Reference: gun, Phase: PhaseStage, Err: errors.Wrap(err, "error establishing connection to trust repository")}
+	}
+	defer release()
+	// whether or not publishing succeeds, the targets may have changed
+	names := make([]string, len(targets))
+	for i, target := range targets {
+		names[i] = target.Name
 	}
-	log.Info("Signing and pushing trust metadata")
+	defer config.TargetCache.Invalidate(repo.GetGUN(), names...)
+
+	// Changes left staged by a failed operation must not be published with
+	// these targets, nor these targets by a later operation if this one
+	// fails.
+	if err := clearChangeList(repo); err != nil {
+		return nil, ErrTrustPush{Reference: gun, Phase: PhaseStage, Err: errors.Wrap(err, "failed to clear changelist")}
+	}
+	defer clearChangeList(repo)
+	config.Log().Info("Signing and pushing trust metadata")
 	_, err = repo.ListTargets()
 
+	// roles[i] are the roles targets[i] is signed into
+	var roles [][]data.RoleName
 	switch err.(type) {
 	case client.ErrRepoNotInitialized, client.ErrRepositoryNotExist:
-		keys := repo.GetCryptoService().ListKeys(data.CanonicalRootRole)
-		var rootKeyID string
-		// always select the first root key
-		if len(keys) > 0 {
-			sort.Strings(keys)
-			rootKeyID = keys[0]
-		} else {
-			rootPublicKey, err := repo.GetCryptoService().Create(data.CanonicalRootRole, "", data.ECDSAKey)
-			if err != nil {
-				log.Errorf("error: %s", err)
-			}
-			rootKeyID = rootPublicKey.ID()
+		if !config.AutoInitialize {
+			return nil, ErrTrustPush{Reference: gun, Phase: PhaseInit, Err: errors.Wrap(err, "trust data is not initialized, initialize it first or enable auto initialization")}
 		}
-		// Initialize the notary repository with a remotely managed snapshot key
-		if err := repo.Initialize([]string{rootKeyID}, data.CanonicalSnapshotRole); err != nil {
-			log.Errorf("error: %s", err)
+		if err := initializeRepository(repo, initOptions{}, config); err != nil {
+			return nil, ErrTrustPush{Reference: gun, Phase: PhaseInit, Err: trust.NotaryError(gun, err)}
+		}
+		config.Log().Infof("Finished initializing %s\n", gun)
+		for _, target := range targets {
+			if err = repo.AddTarget(target, data.CanonicalTargetsRole); err != nil {
+				break
+			}
+			roles = append(roles, []data.RoleName{data.CanonicalTargetsRole})
 		}
-
-		log.Infof("Finished initializing %s\n", ref.Context().Name())
-		err = repo.AddTarget(target, data.CanonicalTargetsRole)
 	case nil:
 		// already initialized and we have successfully downloaded the latest metadata
-		err = addTargetToAllSignableRoles(repo, target)
+		for _, target := range targets {
+			var signedRoles []data.RoleName
+			if signedRoles, err = addTargetToAllSignableRoles(repo, target); err != nil {
+				break
+			}
+			roles = append(roles, signedRoles)
+		}
 	default:
-		return trust.NotaryError(repoInfo.Name(), err)
+		return nil, ErrTrustPush{Reference: gun, Phase: PhaseStage, Err: trust.NotaryError(repoInfo.Name(), err)}
 	}
 
-	if err == nil {
-		err = repo.Publish()
+	if err != nil {
+		return nil, ErrTrustPush{Reference: gun, Phase: PhaseStage, Err: trust.NotaryError(gun, err)}
+	}
+	if err := repo.Publish(); err != nil {
+		return nil, ErrTrustPush{Reference: gun, Phase: PhasePublish, Err: trust.NotaryError(gun, err)}
 	}
 
-	if err != nil {
-		log.Infof("failed to sign: %s", err)
+	// Refresh the cached trust data to learn the published version.
+	version := 0
+	if _, err := repo.ListTargets(); err == nil {
+		version, err = trust.PublishedVersion(repo)
+		if err != nil {
+			config.Log().Warnf("failed to read published version of %s: %s", gun, err)
+		}
+	} else {
+		config.Log().Warnf("failed to refresh trust data of %s: %s", gun, err)
 	}
-	log.Infof("Successfully signed %s:%s\n", ref.Context().Name(), ref.Identifier())
-	return nil
+
+	results := make([]*trust.SignResult, len(targets))
+	for i, target := range targets {
+		digest, _ := targetDigest(target)
+		results[i] = &trust.SignResult{
+			GUN:     repo.GetGUN(),
+			Name:    target.Name,
+			Digest:  digest,
+			Length:  target.Length,
+			Roles:   roles[i],
+			Version: version,
+		}
+		config.Log().Debugf("signed %s:%s into %v", gun, target.Name, roles[i])
+	}
+	return results, nil
 }
 
 // addTargetToAllSignableRoles attempts to add the image target to all the top level delegation roles we can
 // (based on whether we have the signing key and whether the role's path allows
 // us to).
 // If there are no delegation roles, we add to the targets role.
-func addTargetToAllSignableRoles(repo client.Repository, target *client.Target) error {
+func addTargetToAllSignableRoles(repo client.Repository, target *client.Target) ([]data.RoleName, error) {
 	signableRoles, err := trust.GetSignableRoles(repo, target)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return repo.AddTarget(target, signableRoles...)
+	return signableRoles, repo.AddTarget(target, signableRoles...)
 }
@@ -0,0 +1,46 @@
+package gcr
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/seeeverything/notary-gcr/trust"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/client/changelist"
+	"github.com/theupdateframework/notary/passphrase"
+	"github.com/theupdateframework/notary/tuf/data"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestPublishTargetsClearsChangelist(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "notary-test-")
+	assert.NilError(t, err)
+	defer os.RemoveAll(tmpDir)
+	// the notary server has no trust data
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	// a change left staged by a failed operation
+	cl, err := changelist.NewFileChangelist(filepath.Join(tmpDir, "trust", "tuf", "example.com", "foo", "changelist"))
+	assert.NilError(t, err)
+	assert.NilError(t, cl.Add(changelist.NewTUFChange(changelist.ActionCreate, data.CanonicalTargetsRole, changelist.TypeTargetsTarget, "stale", nil)))
+	assert.Check(t, is.Len(cl.List(), 1))
+
+	ref, _ := name.ParseReference("example.com/foo:latest", name.WeakValidation)
+	config := &trust.Config{RootPath: tmpDir, ServerUrl: server.URL, Transport: server.Client().Transport, PassRetriever: passphrase.ConstantRetriever("password")}
+	target := &client.Target{Name: "latest", Hashes: data.Hashes{"sha256": make([]byte, 32)}, Length: 1}
+	_, err = publishTargets(context.Background(), ref, nil, config, target)
+	assert.Check(t, is.ErrorType(err, ErrTrustPush{}))
+	assert.Check(t, is.Len(cl.List(), 0))
+}
@@ -1,87 +1,105 @@
 package gcr
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/seeeverything/notary-gcr/trust"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/pkg/errors"
-	log "github.com/sirupsen/logrus"
 	"github.com/theupdateframework/notary/client"
 	"github.com/theupdateframework/notary/tuf/data"
 )
 
-func revokeImage(ref name.Reference, tag string, auth authn.Authenticator, config *trust.Config) error {
+func revokeImage(ctx context.Context, ref name.Reference, tag string, auth authn.Authenticator, config *trust.Config) (*trust.RevokeResult, error) {
 	repoInfo := ref.Context().Registry
-	notaryRepo, err := trust.GetNotaryRepository(ref, auth, &repoInfo, config)
+	notaryRepo, release, err := trust.AcquireNotaryRepository(ctx, ref, auth, &repoInfo, config, false)
 	if err != nil {
-		return errors.Wrap(err, "error establishing connection to trust repository")
+		return nil, errors.Wrap(err, "error establishing connection to trust repository")
+	}
+	defer release()
+	if tag != "" {
+		defer config.TargetCache.Invalidate(notaryRepo.GetGUN(), tag)
+	} else {
+		defer config.TargetCache.Invalidate(notaryRepo.GetGUN())
 	}
 
 	if err = clearChangeList(notaryRepo); err != nil {
-		return err
+		return nil, err
 	}
 	defer clearChangeList(notaryRepo)
-	if err := revokeSignature(notaryRepo, tag); err != nil {
-		return errors.Wrapf(err, "could not remove signature for %s", tag)
+	revoked, err := revokeSignature(notaryRepo, tag)
+	if err != nil {
+		return nil, errors.Wrapf(trust.NotaryError(ref.Context().Name(), err), "could not remove signature for %s", tag)
 	}
-	log.Infof("Successfully deleted signature for %s\n", tag)
-	return nil
+	config.Log().Debugf("deleted signature for %s", tag)
+	return &trust.RevokeResult{GUN: notaryRepo.GetGUN(), Targets: revoked}, nil
 }
 
-func revokeSignature(notaryRepo client.Repository, tag string) error {
+func revokeSignature(notaryRepo client.Repository, tag string) ([]trust.RevokedTarget, error) {
+	var (
+		revoked []trust.RevokedTarget
+		err     error
+	)
 	if tag != "" {
 		// Revoke signature for the specified tag
-		if err := revokeSingleSig(notaryRepo, tag); err != nil {
-			return err
-		}
+		revoked, err = revokeSingleSig(notaryRepo, tag)
 	} else {
 		// revoke all signatures for the image, as no tag was given
-		if err := revokeAllSigs(notaryRepo); err != nil {
-			return err
-		}
+		revoked, err = revokeAllSigs(notaryRepo)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	//  Publish change
-	return notaryRepo.Publish()
+	return revoked, notaryRepo.Publish()
 }
 
-func revokeSingleSig(notaryRepo client.Repository, tag string) error {
+func revokeSingleSig(notaryRepo client.Repository, tag string) ([]trust.RevokedTarget, error) {
 	releasedTargetWithRole, err := notaryRepo.GetTargetByName(tag, trust.ReleasesRole, data.CanonicalTargetsRole)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	releasedTarget := releasedTargetWithRole.Target
-	return getSignableRolesForTargetAndRemove(releasedTarget, notaryRepo)
+	revoked, err := getSignableRolesForTargetAndRemove(releasedTarget, notaryRepo)
+	if err != nil {
+		return nil, err
+	}
+	return []trust.RevokedTarget{revoked}, nil
 }
 
-func revokeAllSigs(notaryRepo client.Repository) error {
+func revokeAllSigs(notaryRepo client.Repository) ([]trust.RevokedTarget, error) {
 	releasedTargetWithRoleList, err := notaryRepo.ListTargets(trust.ReleasesRole, data.CanonicalTargetsRole)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(releasedTargetWithRoleList) == 0 {
-		return fmt.Errorf("no signed tags to remove")
+		return nil, fmt.Errorf("no signed tags to remove")
 	}
 
 	// we need all the roles that signed each released target so we can remove from all roles.
+	var revoked []trust.RevokedTarget
 	for _, releasedTargetWithRole := range releasedTargetWithRoleList {
 		// remove from all roles
-		if err := getSignableRolesForTargetAndRemove(releasedTargetWithRole.Target, notaryRepo); err != nil {
-			return err
+		r, err := getSignableRolesForTargetAndRemove(releasedTargetWithRole.Target, notaryRepo)
+		if err != nil {
+			return nil, err
 		}
+		revoked = append(revoked, r)
 	}
-	return nil
+	return revoked, nil
 }
 
 // get all the roles that signed the target and removes it from all roles.
-func getSignableRolesForTargetAndRemove(releasedTarget client.Target, notaryRepo client.Repository) error {
+func getSignableRolesForTargetAndRemove(releasedTarget client.Target, notaryRepo client.Repository) (trust.RevokedTarget, error) {
 	signableRoles, err := trust.GetSignableRoles(notaryRepo, &releasedTarget)
 	if err != nil {
-		return err
+		return trust.RevokedTarget{}, err
 	}
 	// remove from all roles
-	return notaryRepo.RemoveTarget(releasedTarget.Name, signableRoles...)
+	revoked := trust.RevokedTarget{Name: releasedTarget.Name, Roles: signableRoles}
+	return revoked, notaryRepo.RemoveTarget(releasedTarget.Name, signableRoles...)
 }
@@ -0,0 +1,35 @@
+package gcr
+
+import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+	"github.com/seeeverything/notary-gcr/trust"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// rotateKey replaces the keys of role in the trust data of ref's repository
+// and publishes the change. Unless serverManaged, the role is signed with
+// the local keys keyIDs, or with a newly generated key if there is none.
+func rotateKey(ctx context.Context, ref name.Reference, role data.RoleName, serverManaged bool, keyIDs []string, auth authn.Authenticator, config *trust.Config) error {
+	repoInfo := ref.Context().Registry
+	notaryRepo, release, err := trust.AcquireNotaryRepository(ctx, ref, auth, &repoInfo, config, false)
+	if err != nil {
+		return errors.Wrap(err, "error establishing connection to trust repository")
+	}
+	defer release()
+
+	// RotateKey publishes the change itself, without going through the
+	// changelist.
+	if err := notaryRepo.RotateKey(role, serverManaged, keyIDs); err != nil {
+		return trust.NotaryError(ref.Context().Name(), err)
+	}
+	if serverManaged {
+		config.Log().Infof("Successfully rotated %s key of %s, now managed by the server\n", role, ref.Context().Name())
+	} else {
+		config.Log().Infof("Successfully rotated %s key of %s\n", role, ref.Context().Name())
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package gcr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/seeeverything/notary-gcr/trust"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+// keyRotationServer records the key rotations requested from a notary
+// server with no trust data.
+func keyRotationServer() (*httptest.Server, func() []string) {
+	var (
+		mu        sync.Mutex
+		rotations []string
+	)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/" {
+			return
+		}
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, ".key") {
+			mu.Lock()
+			rotations = append(rotations, r.URL.Path)
+			mu.Unlock()
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		r := rotations
+		rotations = nil
+		return r
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	server, rotations := keyRotationServer()
+	defer server.Close()
+	ref, _ := name.ParseReference("example.com/foo:latest", name.WeakValidation)
+	repo, err := NewTrustedGcrRepositoryWithOptions(ref, nil,
+		WithServerURL(server.URL),
+		WithTransport(server.Client().Transport),
+		WithInMemory(nil),
+		WithPassphrases("root", "repository"),
+	)
+	assert.NilError(t, err)
+	ctx := context.Background()
+
+	// the server generates the timestamp key, and the snapshot key when
+	// server managed
+	err = repo.RotateTimestampKey(ctx)
+	assert.Check(t, is.ErrorContains(err, "unable to rotate remote key"))
+	assert.Check(t, is.DeepEqual(rotations(), []string{"/v2/example.com/foo/_trust/tuf/timestamp.key"}))
+	err = repo.RotateSnapshotKey(ctx, true, nil)
+	assert.Check(t, is.ErrorContains(err, "unable to rotate remote key"))
+	assert.Check(t, is.DeepEqual(rotations(), []string{"/v2/example.com/foo/_trust/tuf/snapshot.key"}))
+
+	// the other keys are local, and fail to publish without trust data
+	for _, rotate := range []func() error{
+		func() error { return repo.RotateRootKey(ctx, nil) },
+		func() error { return repo.RotateTargetsKey(ctx, nil) },
+		func() error { return repo.RotateSnapshotKey(ctx, false, nil) },
+	} {
+		err = rotate()
+		assert.Check(t, errors.Is(err, trust.ErrNoTrustData), "%v", err)
+		assert.Check(t, is.Len(rotations(), 0))
+	}
+
+	err = repo.RotateTargetsKey(ctx, []string{"missing"})
+	assert.Check(t, is.ErrorContains(err, "unable to find key: missing"))
+}
+
+func TestRotateKeyReadOnly(t *testing.T) {
+	server, rotations := keyRotationServer()
+	defer server.Close()
+	ref, _ := name.ParseReference("example.com/foo:latest", name.WeakValidation)
+	repo, err := NewTrustedGcrRepositoryWithOptions(ref, nil,
+		WithServerURL(server.URL),
+		WithTransport(server.Client().Transport),
+		WithInMemory(nil),
+		WithReadOnly(),
+	)
+	assert.NilError(t, err)
+	ctx := context.Background()
+
+	assert.Check(t, errors.Is(repo.RotateRootKey(ctx, nil), trust.ErrReadOnly))
+	assert.Check(t, errors.Is(repo.RotateTargetsKey(ctx, nil), trust.ErrReadOnly))
+	assert.Check(t, errors.Is(repo.RotateSnapshotKey(ctx, true, nil), trust.ErrReadOnly))
+	assert.Check(t, errors.Is(repo.RotateTimestampKey(ctx), trust.ErrReadOnly))
+	assert.Check(t, is.Len(rotations(), 0))
+}
@@ -1,12 +1,38 @@
 package gcr
 
 import (
+	"context"
+
 	"github.com/seeeverything/notary-gcr/trust"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pkg/errors"
+	"github.com/theupdateframework/notary/client"
 )
 
-func signImage(ref name.Reference, img v1.Image, auth authn.Authenticator, config *trust.Config) error {
-	return pushTrustedReference(ref, img, auth, config)
+func signImage(ctx context.Context, ref name.Reference, img v1.Image, auth authn.Authenticator, config *trust.Config) (*trust.SignResult, error) {
+	return pushTrustedReference(ctx, ref, img, auth, config)
+}
+
+// signImageTags signs img under each of tags in the repository of ref, and
+// publishes all of them in a single trust data update.
+func signImageTags(ctx context.Context, ref name.Reference, img v1.Image, tags []string, auth authn.Authenticator, config *trust.Config) ([]*trust.SignResult, error) {
+	var targets []*client.Target
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		if _, err := name.NewTag(ref.Context().Name()+":"+tag, name.WeakValidation); err != nil {
+			return nil, ErrTrustPush{Reference: ref.Context().Name(), Phase: PhaseStage, Err: errors.Wrapf(err, "invalid tag %q", tag)}
+		}
+		target, err := newTarget(tag, img)
+		if err != nil {
+			return nil, ErrTrustPush{Reference: ref.Context().Name(), Phase: PhaseStage, Err: err}
+		}
+		targets = append(targets, target)
+	}
+	return publishTargets(ctx, ref, auth, config, targets...)
 }
@@ -0,0 +1,84 @@
+package gcr
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/seeeverything/notary-gcr/trust"
+	"github.com/theupdateframework/notary/tuf/data"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestSignImageTagsInvalidTag(t *testing.T) {
+	img, err := random.Image(1024, 1)
+	assert.NilError(t, err)
+
+	ref, _ := name.ParseReference("dockerhub.com/foo/image:1.4.2", name.WeakValidation)
+	_, err = signImageTags(context.Background(), ref, img, []string{"1.4.2", "not a tag"}, nil, &trust.Config{})
+	assert.ErrorContains(t, err, `invalid tag "not a tag"`)
+}
+
+func TestSignImageTags(t *testing.T) {
+	registry := httptest.NewServer(newTestRegistry())
+	defer registry.Close()
+	notary := newTestNotaryServer()
+	defer notary.Close()
+
+	ref := testReference(t, registry, "foo/image:1.0")
+	gun := data.GUN(ref.Context().Name())
+	img, err := random.Image(1024, 1)
+	assert.NilError(t, err)
+	assert.NilError(t, remote.Write(ref, img))
+	digest, err := img.Digest()
+	assert.NilError(t, err)
+	manifest, err := img.RawManifest()
+	assert.NilError(t, err)
+
+	repo, err := NewTrustedGcrRepositoryWithOptions(ref, nil,
+		WithServerURL(notary.URL),
+		WithTransport(notary.Client().Transport),
+		WithInMemory(nil),
+		WithPassphrases("root", "repository"),
+	)
+	assert.NilError(t, err)
+	defer repo.Close()
+	ctx := context.Background()
+	assert.NilError(t, repo.Initialize(ctx, WithLocalSnapshotKey()))
+	assert.Check(t, is.Equal(notary.Publishes(gun), 1))
+
+	results, err := repo.SignImageTags(ctx, img, []string{"1.0", "latest", "1.0"})
+	assert.NilError(t, err)
+	// all the tags are published at once
+	assert.Check(t, is.Equal(notary.Publishes(gun), 2))
+	assert.Assert(t, is.Len(results, 2))
+	version := notary.SnapshotVersion(t, gun)
+	assert.Check(t, version > 1)
+	for i, tag := range []string{"1.0", "latest"} {
+		assert.Check(t, is.DeepEqual(results[i], &trust.SignResult{
+			GUN:     gun,
+			Name:    tag,
+			Digest:  digest,
+			Length:  int64(len(manifest)),
+			Roles:   []data.RoleName{data.CanonicalTargetsRole},
+			Version: version,
+		}))
+	}
+
+	targets := notary.Targets(t, gun)
+	assert.Check(t, is.Len(targets, 2))
+	for _, tag := range []string{"1.0", "latest"} {
+		assert.Check(t, is.Equal(hex.EncodeToString(targets[tag].Hashes["sha256"]), digest.Hex), tag)
+		assert.Check(t, is.Equal(targets[tag].Length, int64(len(manifest))), tag)
+	}
+
+	// the signed tags verify against the registry
+	verified, _, err := repo.VerifyImage(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(verified.DigestStr(), digest.String()))
+}
@@ -1,36 +1,109 @@
 package gcr
 
 import (
+	"context"
+
 	"github.com/seeeverything/notary-gcr/trust"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/pkg/errors"
-	log "github.com/sirupsen/logrus"
 	"github.com/theupdateframework/notary/client"
 	"github.com/theupdateframework/notary/tuf/data"
 )
 
-func getTrustedTarget(ref name.Reference, auth authn.Authenticator, config *trust.Config) (*client.Target, error) {
+func getTrustedTarget(ctx context.Context, ref name.Reference, auth authn.Authenticator, config *trust.Config) (*client.TargetWithRole, error) {
+	tag, err := name.NewTag(ref.String(), name.StrictValidation)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't parse tag from repository name")
+	}
+	gun := config.GUN(ref)
+	if t, ok := config.TargetCache.Get(gun, tag.Identifier()); ok {
+		config.Log().Debugf("using cached target of %s", ref)
+		return t, nil
+	}
+
 	repoInfo := ref.Context().Registry
-	notaryRepo, err := trust.GetNotaryRepository(ref, auth, &repoInfo, config)
+	notaryRepo, release, err := trust.AcquireNotaryRepository(ctx, ref, auth, &repoInfo, config, true)
 	if err != nil {
-		return nil, errors.Wrap(err, "error establishing connection to trust repository")
+		err = errors.Wrap(err, "error establishing connection to trust repository")
+		if errors.Is(err, trust.ErrNetwork) {
+			return staleTarget(config, gun, tag.Identifier(), err)
+		}
+		return nil, err
 	}
-	tag, err := name.NewTag(ref.String(), name.StrictValidation)
+	defer release()
+
+	t, err := trustedTarget(notaryRepo, ref, tag.Identifier())
 	if err != nil {
-		return nil, errors.Wrap(err, "couldn't parse tag from repository name")
+		if errors.Is(err, trust.ErrNetwork) {
+			return staleTarget(config, gun, tag.Identifier(), err)
+		}
+		return nil, err
+	}
+	config.TargetCache.Add(gun, tag.Identifier(), t)
+
+	config.Log().Debugf("retrieving target for %s role", t.Role)
+	return t, nil
+}
+
+// staleTarget returns the target cached for tag in gun when err kept the
+// notary server from being reached, as long as the target is not older than
+// the maximum staleness of config.TargetCache. It returns err otherwise.
+func staleTarget(config *trust.Config, gun data.GUN, tag string, err error) (*client.TargetWithRole, error) {
+	t, ok := config.TargetCache.GetStale(gun, tag)
+	if !ok {
+		return nil, err
 	}
+	config.Log().Warnf("using stale target of %s:%s: %s", gun, tag, err)
+	return t, nil
+}
 
-	t, err := notaryRepo.GetTargetByName(tag.Identifier(), trust.ReleasesRole, data.CanonicalTargetsRole)
+// trustedTarget returns the target signed under targetName in the
+// repository of ref.
+func trustedTarget(notaryRepo client.Repository, ref name.Reference, targetName string) (*client.TargetWithRole, error) {
+	t, err := notaryRepo.GetTargetByName(targetName, trust.ReleasesRole, data.CanonicalTargetsRole)
 	if err != nil {
 		return nil, trust.NotaryError(ref.Name(), err)
 	}
 	// Only get the tag if it's in the top level targets role or the releases delegation role
 	// ignore it if it's in any other delegation roles
 	if t.Role != trust.ReleasesRole && t.Role != data.CanonicalTargetsRole {
-		return nil, trust.NotaryError(ref.Name(), errors.Errorf("No trust data for %s", tag.Identifier()))
+		return nil, trust.NotaryError(ref.Name(), client.ErrNoSuchTarget(targetName))
+	}
+	return t, nil
+}
+
+// verifyImage checks that ref resolves in the registry to the manifest
+// signed for it, and returns the reference to that manifest by digest.
+func verifyImage(ctx context.Context, ref name.Reference, auth authn.Authenticator, config *trust.Config) (name.Digest, *client.Target, error) {
+	t, err := getTrustedTarget(ctx, ref, auth, config)
+	if err != nil {
+		return name.Digest{}, nil, err
+	}
+	target := &t.Target
+	digest, err := verifyManifest(ctx, ref, target, auth, config)
+	if err != nil {
+		return name.Digest{}, nil, err
 	}
+	return digest, target, nil
+}
 
-	log.Debugf("retrieving target for %s role", t.Role)
-	return &t.Target, nil
+// verifyManifest checks that ref resolves in the registry to the manifest
+// signed as target, and returns the reference to that manifest by digest.
+func verifyManifest(ctx context.Context, ref name.Reference, target *client.Target, auth authn.Authenticator, config *trust.Config) (name.Digest, error) {
+	desc, err := remote.Get(ref, remote.WithAuth(auth), remote.WithTransport(registryTransport(ctx, config)))
+	if err != nil {
+		return name.Digest{}, errors.Wrap(err, "couldn't fetch remote manifest")
+	}
+	if err := matchTarget(ref, target, desc.Descriptor); err != nil {
+		return name.Digest{}, err
+	}
+
+	digest, err := digestReference(ref.Context(), desc.Digest)
+	if err != nil {
+		return name.Digest{}, err
+	}
+	config.Log().Debugf("%s verified as %s", ref, digest)
+	return digest, nil
 }
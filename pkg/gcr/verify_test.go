@@ -0,0 +1,103 @@
+package gcr
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/seeeverything/notary-gcr/trust"
+	"github.com/theupdateframework/notary/client"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestGetTrustedTargetCached(t *testing.T) {
+	// the notary server is unavailable
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	ref, _ := name.ParseReference("example.com/foo/image:1.0", name.WeakValidation)
+	target := &client.TargetWithRole{Target: client.Target{Name: "1.0", Length: 1}, Role: trust.ReleasesRole}
+	config := &trust.Config{InMemory: true, ServerUrl: server.URL, TargetCache: trust.NewTargetCache(time.Hour, time.Hour)}
+	config.TargetCache.Add("example.com/foo/image", "1.0", target)
+	got, err := getTrustedTarget(context.Background(), ref, nil, config)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(got, target))
+
+	// a stale target is used while the server is unavailable
+	config.TargetCache = trust.NewTargetCache(0, time.Hour)
+	config.TargetCache.Add("example.com/foo/image", "1.0", target)
+	got, err = getTrustedTarget(context.Background(), ref, nil, config)
+	assert.NilError(t, err)
+	assert.Check(t, is.DeepEqual(got, target))
+
+	config.TargetCache.Invalidate("example.com/foo/image")
+	_, err = getTrustedTarget(context.Background(), ref, nil, config)
+	assert.ErrorContains(t, err, "error establishing connection to trust repository")
+}
+
+func TestGetTrustedTargetNotStale(t *testing.T) {
+	tokenStatus := http.StatusUnauthorized
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="https://`+r.Host+`/token",service="notary"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/token":
+			w.WriteHeader(tokenStatus)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	// the server is not named localhost so that it is not also tried over http
+	dial := func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+	trusted := server.Client().Transport.(*http.Transport).Clone()
+	trusted.DialContext = dial
+	trusted.TLSClientConfig.ServerName = "example.com"
+
+	ref, _ := name.ParseReference("example.com/foo/image:1.0", name.WeakValidation)
+	target := &client.TargetWithRole{Target: client.Target{Name: "1.0", Length: 1}, Role: trust.ReleasesRole}
+	for _, c := range []struct {
+		name        string
+		tokenStatus int
+		transport   http.RoundTripper
+	}{
+		{name: "unauthorized", tokenStatus: http.StatusUnauthorized, transport: trusted},
+		{name: "forbidden", tokenStatus: http.StatusForbidden, transport: trusted},
+		{name: "untrusted certificate", transport: &http.Transport{DialContext: dial}},
+	} {
+		tokenStatus = c.tokenStatus
+		config := &trust.Config{InMemory: true, ServerUrl: "https://example.com", Transport: c.transport, TargetCache: trust.NewTargetCache(0, time.Hour)}
+		config.TargetCache.Add("example.com/foo/image", "1.0", target)
+
+		_, err := getTrustedTarget(context.Background(), ref, nil, config)
+		assert.Check(t, err != nil, c.name)
+		assert.Check(t, !errors.Is(err, trust.ErrNetwork), "%s: %v", c.name, err)
+
+		// as VerifyImages finds it
+		targets, unavailable, err := trustedTargets(context.Background(), ref, nil, config)
+		_, err = targetOf(config, "example.com/foo/image", targets, unavailable, err, ref)
+		assert.Check(t, err != nil, c.name)
+		assert.Check(t, !unavailable, c.name)
+	}
+}
+
+func TestVerifyServerUnavailable(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	ref, _ := name.ParseReference("example.com/foo/image:1.0", name.WeakValidation)
+	repo, err := NewTrustedGcrRepositoryWithOptions(ref, nil, WithServerURL(server.URL), WithInMemory(nil))
+	assert.NilError(t, err)
+	_, err = repo.VerifyContext(context.Background())
+	assert.Check(t, errors.Is(err, trust.ErrNetwork), "%v", err)
+	assert.Check(t, !errors.Is(err, trust.ErrNoTrustData))
+}
@@ -0,0 +1,52 @@
+package trust
+
+import (
+	"net/url"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+)
+
+// RegistryAuth returns the authenticator for registry: auth if it is set,
+// else the credentials of Keychain for registry, else anonymous access.
+func (c *Config) RegistryAuth(registry name.Registry, auth authn.Authenticator) (authn.Authenticator, error) {
+	if auth != nil {
+		return auth, nil
+	}
+	if c.Keychain == nil {
+		return authn.Anonymous, nil
+	}
+	auth, err := c.Keychain.Resolve(registry)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve credentials for %s", registry)
+	}
+	return auth, nil
+}
+
+// notaryAuth returns the authenticator for the notary server: NotaryAuth if
+// it is set, else the credentials of Keychain for the server, else
+// registryAuth.
+func (c *Config) notaryAuth(server string, registryAuth authn.Authenticator) (authn.Authenticator, error) {
+	if c.NotaryAuth != nil {
+		return c.NotaryAuth, nil
+	}
+	if c.Keychain != nil {
+		u, err := url.Parse(server)
+		if err != nil {
+			return nil, err
+		}
+		registry, err := name.NewRegistry(u.Host, name.WeakValidation)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid trust server %s", server)
+		}
+		auth, err := c.Keychain.Resolve(registry)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resolve credentials for %s", server)
+		}
+		if auth != authn.Anonymous {
+			return auth, nil
+		}
+	}
+	return registryAuth, nil
+}
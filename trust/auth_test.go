@@ -0,0 +1,60 @@
+package trust
+
+import (
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+type testKeychain map[string]authn.Authenticator
+
+func (k testKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	if auth, ok := k[target.RegistryStr()]; ok {
+		return auth, nil
+	}
+	return authn.Anonymous, nil
+}
+
+func TestRegistryAuth(t *testing.T) {
+	registryAuth := &authn.Basic{Username: "registry"}
+	explicitAuth := &authn.Basic{Username: "explicit"}
+	registry, err := name.NewRegistry("registry.example.com")
+	assert.NilError(t, err)
+
+	config := &Config{}
+	auth, err := config.RegistryAuth(registry, nil)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(auth, authn.Anonymous))
+
+	config.Keychain = testKeychain{"registry.example.com": registryAuth}
+	auth, err = config.RegistryAuth(registry, nil)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(auth, registryAuth))
+
+	auth, err = config.RegistryAuth(registry, explicitAuth)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(auth, explicitAuth))
+}
+
+func TestNotaryAuth(t *testing.T) {
+	registryAuth := &authn.Basic{Username: "registry"}
+	notaryAuth := &authn.Basic{Username: "notary"}
+
+	config := &Config{Keychain: testKeychain{"notary.example.com:4443": notaryAuth}}
+	auth, err := config.notaryAuth("https://notary.example.com:4443", registryAuth)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(auth, notaryAuth))
+
+	auth, err = config.notaryAuth("https://other.example.com", registryAuth)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(auth, registryAuth))
+
+	explicitAuth := &authn.Basic{Username: "explicit"}
+	config.NotaryAuth = explicitAuth
+	auth, err = config.notaryAuth("https://notary.example.com:4443", registryAuth)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(auth, explicitAuth))
+}
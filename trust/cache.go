@@ -0,0 +1,255 @@
+package trust
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/theupdateframework/notary/client"
+)
+
+// DefaultRepositoryMaxAge is the time after which a cached notary
+// repository is rebuilt, with a new connection and token exchange.
+const DefaultRepositoryMaxAge = 10 * time.Minute
+
+// RepositoryCache reuses notary repositories across operations, together
+// with their notary server token, and shares one keep-alive transport per
+// notary server, so that consecutive operations skip the TLS handshake and
+// token exchange. A cached repository is rebuilt once it is older than its
+// max age, or after a round trip of its transport failed. It is safe for
+// concurrent use; operations on the same GUN are serialized. Close releases
+// its idle connections once it is no longer used.
+type RepositoryCache struct {
+	maxAge time.Duration
+	now    func() time.Time
+
+	mu           sync.Mutex
+	repositories map[string]*cachedRepository
+	transports   map[string]*http.Transport
+	registry     *http.Transport
+}
+
+type cachedRepository struct {
+	// mu is held while the repository is in use.
+	mu        sync.Mutex
+	repo      client.Repository
+	transport *switchTransport
+	created   time.Time
+}
+
+// NewRepositoryCache returns a RepositoryCache rebuilding repositories
+// older than maxAge, or DefaultRepositoryMaxAge if it is zero.
+func NewRepositoryCache(maxAge time.Duration) *RepositoryCache {
+	if maxAge == 0 {
+		maxAge = DefaultRepositoryMaxAge
+	}
+	return &RepositoryCache{
+		maxAge:       maxAge,
+		now:          time.Now,
+		repositories: make(map[string]*cachedRepository),
+		transports:   make(map[string]*http.Transport),
+	}
+}
+
+// notaryTransport returns the keep-alive transport shared by the notary
+// repositories of server, built by build the first time.
+func (c *RepositoryCache) notaryTransport(server string, build func() (*http.Transport, error)) (*http.Transport, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t, ok := c.transports[server]; ok {
+		return t, nil
+	}
+	t, err := build()
+	if err != nil {
+		return nil, err
+	}
+	c.transports[server] = t
+	return t, nil
+}
+
+// Close closes the idle connections of the transports of the cache, and
+// releases the transports and the repositories cached. The cache can still
+// be used afterwards, building new ones.
+func (c *RepositoryCache) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range c.transports {
+		t.CloseIdleConnections()
+	}
+	if c.registry != nil {
+		c.registry.CloseIdleConnections()
+	}
+	c.repositories = make(map[string]*cachedRepository)
+	c.transports = make(map[string]*http.Transport)
+	c.registry = nil
+}
+
+// RegistryTransport returns the keep-alive transport shared by the
+// registry operations using the cache.
+func (c *RepositoryCache) RegistryTransport() http.RoundTripper {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.registry == nil {
+		c.registry = &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+			DisableCompression:  true,
+		}
+	}
+	return c.registry
+}
+
+// acquire returns the repository cached under key, built by create over a
+// new switchTransport if it is missing or stale, with its transport bound to
+// ctx. The repository is reserved to the caller until release is called.
+func (c *RepositoryCache) acquire(ctx context.Context, key string, create func(*switchTransport) (client.Repository, error)) (repo client.Repository, release func(), err error) {
+	c.mu.Lock()
+	cached, ok := c.repositories[key]
+	if !ok {
+		cached = &cachedRepository{}
+		c.repositories[key] = cached
+	}
+	c.mu.Unlock()
+
+	cached.mu.Lock()
+	if cached.repo == nil || cached.transport.hasFailed() || c.now().Sub(cached.created) > c.maxAge {
+		if cached.repo != nil && cached.transport.hasFailed() {
+			// drop the connections which may be broken
+			if t, ok := cached.transport.inner.(interface{ CloseIdleConnections() }); ok {
+				t.CloseIdleConnections()
+			}
+		}
+		transport := &switchTransport{ctx: ctx}
+		if cached.repo, err = create(transport); err != nil {
+			cached.repo = nil
+			cached.mu.Unlock()
+			return nil, nil, err
+		}
+		cached.transport = transport
+		cached.created = c.now()
+	}
+	cached.transport.setContext(ctx)
+	return cached.repo, func() {
+		cached.transport.setContext(nil)
+		cached.mu.Unlock()
+	}, nil
+}
+
+// switchTransport is a contextTransport whose context is switched to the
+// one of each operation using it, and which records transport failures.
+type switchTransport struct {
+	inner http.RoundTripper
+
+	mu     sync.Mutex
+	ctx    context.Context
+	failed bool
+}
+
+func (t *switchTransport) setContext(ctx context.Context) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ctx = ctx
+}
+
+func (t *switchTransport) hasFailed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.failed
+}
+
+func (t *switchTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	ctx := t.ctx
+	t.mu.Unlock()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	resp, err := NewContextTransport(ctx, t.inner).RoundTrip(req)
+	if err != nil && ctx.Err() == nil {
+		t.mu.Lock()
+		t.failed = true
+		t.mu.Unlock()
+	}
+	return resp, err
+}
+
+// AcquireNotaryRepository is like GetNotaryRepositoryWithContext, or
+// GetReadOnlyNotaryRepository if readOnly is set, but reuses the repository
+// cached in config.RepositoryCache, if any. The repository is reserved to
+// the caller until release is called. Unless readOnly is set, the trust
+// data of the repository is also locked in the trust directory until then,
+// so that other processes cannot change its changelist or publish it.
+// Within the process, reads of the trust data of the repository wait for
+// its changes, and changes for its reads.
+func AcquireNotaryRepository(ctx context.Context, ref name.Reference, auth authn.Authenticator, repoInfo *name.Registry, config *Config, readOnly bool) (repo client.Repository, release func(), err error) {
+	actions := ActionsPullOnly
+	if !readOnly {
+		if config.ReadOnly {
+			return nil, nil, ErrReadOnly
+		}
+		actions = ActionsPushAndPull
+	}
+
+	unlock, err := lockGUN(ctx, config, config.GUN(ref), readOnly)
+	if err != nil {
+		return nil, nil, err
+	}
+	repo, releaseRepo, err := acquireNotaryRepository(ctx, ref, auth, repoInfo, config, actions)
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	return repo, func() {
+		releaseRepo()
+		unlock()
+	}, nil
+}
+
+func acquireNotaryRepository(ctx context.Context, ref name.Reference, auth authn.Authenticator, repoInfo *name.Registry, config *Config, actions []string) (repo client.Repository, release func(), err error) {
+	cache := config.RepositoryCache
+	if cache == nil {
+		repo, err = getNotaryRepository(ctx, ref, auth, repoInfo, config, actions)
+		if err != nil {
+			return nil, nil, err
+		}
+		return repo, func() {}, nil
+	}
+
+	server, err := Server(config.ServerUrl, repoInfo)
+	if err != nil {
+		return nil, nil, err
+	}
+	key := server + " " + config.GUN(ref).String() + " " + strings.Join(actions, ",")
+	repo, release, err = cache.acquire(ctx, key, func(t *switchTransport) (client.Repository, error) {
+		base, err := config.notaryBaseTransport(server)
+		if err != nil {
+			return nil, err
+		}
+		t.inner = base
+		return newNotaryRepository(ref, auth, config, server, t, actions)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if config.InMemory {
+		// like a new in-memory repository, start with an empty changelist
+		release = clearOnRelease(repo, release)
+	}
+	return repo, release, nil
+}
+
+func clearOnRelease(repo client.Repository, release func()) func() {
+	return func() {
+		if cl, err := repo.GetChangelist(); err == nil {
+			cl.Clear("")
+		}
+		release()
+	}
+}
@@ -0,0 +1,176 @@
+package trust
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestAcquireNotaryRepository(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "notary-test-")
+	assert.NilError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	tokens := 0
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			tokens++
+			w.Write([]byte(`{"token": "abc"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer abc" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="notary-server"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	cache := NewRepositoryCache(time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	ref, _ := name.ParseReference("example.com/foo/image:latest", name.WeakValidation)
+	repoInfo := ref.Context().Registry
+	config := &Config{RootPath: tmpDir, ServerUrl: server.URL, Transport: server.Client().Transport, RepositoryCache: cache}
+
+	first, release, err := AcquireNotaryRepository(context.Background(), ref, nil, &repoInfo, config, true)
+	assert.NilError(t, err)
+	release()
+	second, release, err := AcquireNotaryRepository(context.Background(), ref, nil, &repoInfo, config, true)
+	assert.NilError(t, err)
+	release()
+	assert.Check(t, first == second)
+	assert.Check(t, is.Equal(tokens, 1))
+
+	// pull and push scopes use distinct tokens
+	_, release, err = AcquireNotaryRepository(context.Background(), ref, nil, &repoInfo, config, false)
+	assert.NilError(t, err)
+	release()
+	assert.Check(t, is.Equal(tokens, 2))
+
+	now = now.Add(2 * time.Minute)
+	third, release, err := AcquireNotaryRepository(context.Background(), ref, nil, &repoInfo, config, true)
+	assert.NilError(t, err)
+	release()
+	assert.Check(t, first != third)
+	assert.Check(t, is.Equal(tokens, 3))
+}
+
+func TestSwitchTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	transport := &switchTransport{inner: http.DefaultTransport}
+	c := http.Client{Transport: transport}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	transport.setContext(ctx)
+	cancel()
+	_, err := c.Get(server.URL)
+	assert.ErrorContains(t, err, context.Canceled.Error())
+	assert.Check(t, !transport.hasFailed())
+
+	transport.setContext(context.Background())
+	resp, err := c.Get(server.URL)
+	assert.NilError(t, err)
+	resp.Body.Close()
+
+	server.Close()
+	_, err = c.Get(server.URL)
+	assert.Check(t, err != nil)
+	assert.Check(t, transport.hasFailed())
+}
+
+func TestAcquireNotaryRepositoryConcurrently(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "notary-test-")
+	assert.NilError(t, err)
+	defer os.RemoveAll(tmpDir)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	ref, _ := name.ParseReference("example.com/foo/image:latest", name.WeakValidation)
+	repoInfo := ref.Context().Registry
+	config := &Config{RootPath: tmpDir, ServerUrl: server.URL, Transport: server.Client().Transport, RepositoryCache: NewRepositoryCache(0)}
+
+	inUse := make(chan struct{}, 1)
+	errs := make(chan error)
+	for i := 0; i < 8; i++ {
+		go func(readOnly bool) {
+			_, release, err := AcquireNotaryRepository(context.Background(), ref, nil, &repoInfo, config, readOnly)
+			if err == nil {
+				if !readOnly {
+					select {
+					case inUse <- struct{}{}:
+					default:
+						t.Error("trust data locked twice")
+					}
+					time.Sleep(time.Millisecond)
+					<-inUse
+				}
+				release()
+			}
+			errs <- err
+		}(i%2 == 0)
+	}
+	for i := 0; i < 8; i++ {
+		assert.Check(t, <-errs)
+	}
+}
+
+func TestRepositoryCacheSharesTransport(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		connections int
+	)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			connections++
+			mu.Unlock()
+		}
+	}
+	server.StartTLS()
+	defer server.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	cache := NewRepositoryCache(0)
+	config := &Config{InMemory: true, ServerUrl: server.URL, TLS: TLSConfig{CA: string(ca)}, RepositoryCache: cache}
+	config.CryptoService = NewMemoryCryptoService(config)
+	for _, s := range []string{"example.com/foo:latest", "example.com/bar:latest"} {
+		ref, _ := name.ParseReference(s, name.WeakValidation)
+		repoInfo := ref.Context().Registry
+		_, release, err := AcquireNotaryRepository(context.Background(), ref, nil, &repoInfo, config, true)
+		assert.NilError(t, err)
+		release()
+	}
+	mu.Lock()
+	assert.Check(t, is.Equal(connections, 1))
+	mu.Unlock()
+
+	assert.Assert(t, is.Len(cache.transports, 1))
+	for _, transport := range cache.transports {
+		assert.Check(t, transport.IdleConnTimeout > 0)
+	}
+	cache.Close()
+	assert.Check(t, is.Len(cache.transports, 0))
+	assert.Check(t, is.Len(cache.repositories, 0))
+
+	// the cache reconnects after Close
+	ref, _ := name.ParseReference("example.com/foo:latest", name.WeakValidation)
+	repoInfo := ref.Context().Registry
+	_, release, err := AcquireNotaryRepository(context.Background(), ref, nil, &repoInfo, config, true)
+	assert.NilError(t, err)
+	release()
+	assert.Check(t, is.Len(cache.transports, 1))
+	cache.Close()
+}
@@ -3,10 +3,14 @@ package trust
 import (
 	"encoding/json"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
 
+	"github.com/google/go-containerregistry/pkg/authn"
 	log "github.com/sirupsen/logrus"
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/tuf/signed"
 )
 
 type Config struct {
@@ -14,6 +18,61 @@ type Config struct {
 	ServerUrl            string `json:"server_url"`
 	RootPassphrase       string `json:"root_passphrase"`
 	RepositoryPassphrase string `json:"repository_passphrase"`
+	// NonInteractive makes a missing passphrase fail with
+	// ErrPassphraseRequired instead of prompting on stdin.
+	NonInteractive bool `json:"non_interactive"`
+	// KeyAlgorithm is the algorithm of the keys generated when
+	// initializing or rotating keys: ecdsa (the default), ed25519 or rsa.
+	KeyAlgorithm string `json:"key_algorithm"`
+	// AutoInitialize initializes the trust data of a repository when an
+	// image is first signed into it, instead of failing.
+	AutoInitialize bool `json:"auto_initialize"`
+	// ReadOnly only authenticates to the notary server with pull scope, and
+	// makes any change to the trust data fail with ErrReadOnly.
+	ReadOnly bool `json:"read_only"`
+	// InMemory keeps the trust data and the changelist in memory instead of
+	// the trust directory. Keys come from CryptoService, which is then
+	// required, e.g. from NewMemoryCryptoService.
+	InMemory bool `json:"in_memory"`
+	// GUNMapping rewrites GUN prefixes, e.g. a registry mirror to the
+	// registry it mirrors, so that both share one trust collection. The
+	// longest matching prefix is rewritten.
+	GUNMapping map[string]string `json:"gun_mapping"`
+	// TLS is the TLS policy for the notary server. The server certificate
+	// is verified unless TLS.InsecureSkipVerify is set.
+	TLS TLSConfig `json:"tls"`
+	// TrustPinning pins the root of trust of repositories, instead of
+	// trusting the root first downloaded for them.
+	TrustPinning TrustPinningConfig `json:"trust_pinning"`
+
+	// PassRetriever, when set, replaces the passphrase retriever built from
+	// RootPassphrase and RepositoryPassphrase.
+	PassRetriever notary.PassRetriever `json:"-"`
+	// MemoryCache, when set with InMemory, caches the trust data across
+	// operations.
+	MemoryCache *MemoryCache `json:"-"`
+	// CryptoService, when set, holds the signing keys instead of the trust
+	// directory.
+	CryptoService signed.CryptoService `json:"-"`
+	// RepositoryCache, when set, reuses notary repositories and their
+	// connections across the operations of AcquireNotaryRepository.
+	RepositoryCache *RepositoryCache `json:"-"`
+	// TargetCache, when set, caches the targets verified for tags across
+	// operations. Signing and revoking through this Config invalidate it.
+	TargetCache *TargetCache `json:"-"`
+	// NotaryAuth authenticates to the notary server, when its credentials
+	// differ from the registry ones.
+	NotaryAuth authn.Authenticator `json:"-"`
+	// Keychain resolves the credentials of each registry and notary server
+	// for which no authenticator is given, e.g. authn.DefaultKeychain.
+	Keychain authn.Keychain `json:"-"`
+	// Transport, when set, is used as the base transport for the notary
+	// server and the registry instead of the one built from the
+	// certificate directory and TLS.
+	Transport http.RoundTripper `json:"-"`
+	// Logger receives the log output of operations using this Config.
+	// The logrus standard logger is used when it is nil.
+	Logger log.FieldLogger `json:"-"`
 }
 
 const (
@@ -23,14 +82,20 @@ const (
 	defaultConfigFileName = "gcr-config.json"
 )
 
+// DefaultConfigDir returns the configuration directory used when none is
+// given: ${NOTARY_CONFIG_DIR}, or ${HOME}/.notary if it is unset.
+func DefaultConfigDir() string {
+	if configDir := os.Getenv(configDirEnv); configDir != "" {
+		return configDir
+	}
+	return filepath.Join(os.Getenv("HOME"), ".notary")
+}
+
 // ParseConfig read configfile (${configDir}/${configFileName})
 // returns a Config object and error.
 func ParseConfig(configDir string) (*Config, error) {
 	if configDir == "" {
-		configDir = os.Getenv(configDirEnv)
-		if configDir == "" {
-			configDir = filepath.Join(os.Getenv("HOME"), ".notary")
-		}
+		configDir = DefaultConfigDir()
 	}
 	if !filepath.IsAbs(configDir) {
 		log.Warnf("config directory %s maybe wrong, not absolute path", configDir)
@@ -56,3 +121,11 @@ func ParseConfig(configDir string) (*Config, error) {
 	c.RootPath = configDir
 	return c, nil
 }
+
+// Log returns the logger configured for c.
+func (c *Config) Log() log.FieldLogger {
+	if c.Logger == nil {
+		return log.StandardLogger()
+	}
+	return c.Logger
+}
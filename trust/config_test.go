@@ -68,4 +68,31 @@ func TestParsePartialConfig(t *testing.T) {
 	if err := configFile.Close(); err != nil {
 		log.Fatal(err)
 	}
-}
\ No newline at end of file
+}
+func TestDefaultConfigDir(t *testing.T) {
+	os.Setenv("NOTARY_CONFIG_DIR", "/etc/notary")
+	defer os.Unsetenv("NOTARY_CONFIG_DIR")
+	assert.Check(t, is.Equal(DefaultConfigDir(), "/etc/notary"))
+
+	os.Unsetenv("NOTARY_CONFIG_DIR")
+	assert.Check(t, is.Equal(DefaultConfigDir(), filepath.Join(os.Getenv("HOME"), ".notary")))
+}
+
+func TestConfigRuntimeHooks(t *testing.T) {
+	conf := &Config{RootPassphrase: "root"}
+	assert.Check(t, conf.Log() == log.StandardLogger())
+
+	passphrase, _, err := conf.passphraseRetriever()("key", "root", false, 0)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(passphrase, "root"))
+
+	logger := log.New()
+	conf.Logger = logger
+	conf.PassRetriever = func(string, string, bool, int) (string, bool, error) {
+		return "injected", false, nil
+	}
+	assert.Check(t, conf.Log() == logger)
+	passphrase, _, err = conf.passphraseRetriever()("key", "root", false, 0)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(passphrase, "injected"))
+}
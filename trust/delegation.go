@@ -0,0 +1,96 @@
+package trust
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/client/changelist"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/utils"
+)
+
+// ParsePublicKey parses a PEM encoded certificate or public key, as used to
+// add a signer to a delegation role.
+func ParsePublicKey(pemBytes []byte) (data.PublicKey, error) {
+	// Notary does not read PKIX encoded ed25519 keys, as exported by
+	// ExportPublicKey.
+	if block, _ := pem.Decode(pemBytes); block != nil && block.Type == "PUBLIC KEY" {
+		if pub, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+			if edPub, ok := pub.(ed25519.PublicKey); ok {
+				return data.NewED25519PublicKey(edPub), nil
+			}
+		}
+	}
+	return utils.ParsePEMPublicKey(pemBytes)
+}
+
+// LoadPublicKey reads a PEM encoded certificate or public key from path.
+func LoadPublicKey(path string) (data.PublicKey, error) {
+	pemBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pubKey, err := ParsePublicKey(pemBytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse public key from %s", path)
+	}
+	return pubKey, nil
+}
+
+// CheckDelegationRole returns an error if role is not a delegation role
+// directly under the targets role, the only ones targets are signed into.
+func CheckDelegationRole(role data.RoleName) error {
+	if !data.IsDelegation(role) || role.Parent() != data.CanonicalTargetsRole {
+		return errors.Errorf("%s is not a delegation role under %s", role, data.CanonicalTargetsRole)
+	}
+	return nil
+}
+
+// AddDelegation stages the creation of the delegation role in repo, or the
+// addition of keys and paths to it if it already exists. The threshold only
+// applies when the role is created, notary ignoring it for existing roles;
+// a threshold of zero selects notary.MinThreshold.
+func AddDelegation(repo client.Repository, role data.RoleName, keys []data.PublicKey, paths []string, threshold int) error {
+	if err := CheckDelegationRole(role); err != nil {
+		return err
+	}
+	if threshold <= notary.MinThreshold {
+		return repo.AddDelegation(role, keys, paths)
+	}
+
+	roles, err := repo.GetDelegationRoles()
+	if err != nil {
+		return err
+	}
+	for _, r := range roles {
+		if r.Name == role && r.Threshold != threshold {
+			return errors.Errorf("the threshold of the existing role %s is %d and cannot be changed", role, r.Threshold)
+		}
+	}
+
+	tdJSON, err := json.Marshal(&changelist.TUFDelegation{
+		NewThreshold: threshold,
+		AddKeys:      data.KeyList(keys),
+		AddPaths:     paths,
+	})
+	if err != nil {
+		return err
+	}
+	cl, err := repo.GetChangelist()
+	if err != nil {
+		return err
+	}
+	return cl.Add(changelist.NewTUFChange(
+		changelist.ActionCreate,
+		role,
+		changelist.TypeTargetsDelegation,
+		"", // no path for delegations
+		tdJSON,
+	))
+}
@@ -0,0 +1,62 @@
+package trust
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/passphrase"
+	"github.com/theupdateframework/notary/trustpinning"
+	"github.com/theupdateframework/notary/tuf/data"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func generatePublicKeyPEM(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.NilError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestParsePublicKey(t *testing.T) {
+	pubKey, err := ParsePublicKey(generatePublicKeyPEM(t))
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(pubKey.Algorithm(), data.ECDSAKey))
+
+	_, err = ParsePublicKey([]byte("not a key"))
+	assert.Error(t, err, "no valid public key found")
+}
+
+func TestCheckDelegationRole(t *testing.T) {
+	assert.NilError(t, CheckDelegationRole(ReleasesRole))
+	assert.Error(t, CheckDelegationRole(data.CanonicalTargetsRole), "targets is not a delegation role under targets")
+	assert.Error(t, CheckDelegationRole("targets/releases/qa"), "targets/releases/qa is not a delegation role under targets")
+}
+
+func TestAddDelegation(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "notary-test-")
+	assert.NilError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	notaryRepo, err := client.NewFileCachedRepository(tmpDir, "gun", "https://localhost", nil, passphrase.ConstantRetriever("password"), trustpinning.TrustPinConfig{})
+	assert.NilError(t, err)
+	pubKey, err := ParsePublicKey(generatePublicKeyPEM(t))
+	assert.NilError(t, err)
+
+	assert.NilError(t, AddDelegation(notaryRepo, ReleasesRole, []data.PublicKey{pubKey}, []string{""}, 0))
+	cl, err := notaryRepo.GetChangelist()
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(cl.List(), 2))
+
+	// The existing threshold cannot be checked offline
+	err = AddDelegation(notaryRepo, ReleasesRole, []data.PublicKey{pubKey}, []string{""}, 2)
+	assert.ErrorContains(t, err, "client is offline")
+}
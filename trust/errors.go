@@ -0,0 +1,150 @@
+package trust
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"net"
+	"net/http"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/pkg/errors"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/storage"
+	"github.com/theupdateframework/notary/trustmanager"
+	"github.com/theupdateframework/notary/trustpinning"
+	"github.com/theupdateframework/notary/tuf/signed"
+)
+
+// Kinds of notary failures, to be matched with errors.Is against the errors
+// returned by NotaryError.
+var (
+	// ErrNoTrustData is returned when a repository has no trust data, i.e.
+	// its images are not signed.
+	ErrNoTrustData = errors.New("no trust data")
+	// ErrNoSuchTarget is returned when a tag is not signed.
+	ErrNoSuchTarget = errors.New("no such target")
+	// ErrExpired is returned when the trust data has expired.
+	ErrExpired = errors.New("trust data expired")
+	// ErrKeyNotFound is returned when the keys to sign the trust data are
+	// missing or cannot be decrypted.
+	ErrKeyNotFound = errors.New("signing key not found")
+	// ErrNetwork is returned when the notary server cannot be reached.
+	ErrNetwork = errors.New("notary server unavailable")
+	// ErrPotentialAttack is returned when the trust data fails validation,
+	// which may mean it was tampered with.
+	ErrPotentialAttack = errors.New("potential malicious behavior")
+)
+
+// ErrReadOnly is returned when trust data is to be changed with a read-only
+// Config.
+var ErrReadOnly = errors.New("trust data cannot be changed in read-only mode")
+
+// Error is a notary error classified by NotaryError, or a failure to reach,
+// or to verify, the notary server. errors.Is matches it against its Kind,
+// and errors.As against the underlying error.
+type Error struct {
+	// Kind is one of ErrNoTrustData, ErrNoSuchTarget, ErrExpired,
+	// ErrKeyNotFound, ErrNetwork or ErrPotentialAttack.
+	Kind       error
+	Repository string
+	Err        error
+
+	msg string
+}
+
+func (err *Error) Error() string {
+	return err.msg
+}
+
+// Is reports whether target is the kind of err.
+func (err *Error) Is(target error) bool {
+	return target == err.Kind
+}
+
+// Cause returns the underlying notary error, for errors.Cause.
+func (err *Error) Cause() error {
+	return err.Err
+}
+
+// Unwrap returns the underlying notary error, for errors.Is and errors.As.
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
+func newError(kind error, repoName string, err error, format string, args ...interface{}) *Error {
+	return &Error{Kind: kind, Repository: repoName, Err: err, msg: errors.Errorf(format, args...).Error()}
+}
+
+// NotaryError classifies the notary error err on repoName as an *Error,
+// with a message explaining it. Other errors are returned unchanged.
+func NotaryError(repoName string, err error) error {
+	switch err.(type) {
+	case *json.SyntaxError:
+		return newError(ErrNoTrustData, repoName, err, "Error: no trust data available for remote repository %s. Try running notary server and setting DOCKER_CONTENT_TRUST_SERVER to its HTTPS address?", repoName)
+	case signed.ErrExpired:
+		return newError(ErrExpired, repoName, err, "Error: remote repository %s out-of-date: %v", repoName, err)
+	case trustmanager.ErrKeyNotFound:
+		return newError(ErrKeyNotFound, repoName, err, "Error: signing keys for remote repository %s not found: %v", repoName, err)
+	case storage.NetworkError:
+		if certificateError(err.(storage.NetworkError).Wrapped) {
+			return untrustedServerError(repoName, err)
+		}
+		return newError(ErrNetwork, repoName, err, "Error: error contacting notary server: %v", err)
+	case storage.ErrServerUnavailable, storage.ErrOffline:
+		return newError(ErrNetwork, repoName, err, "Error: error contacting notary server: %v", err)
+	case storage.ErrMetaNotFound:
+		return newError(ErrNoTrustData, repoName, err, "Error: trust data missing for remote repository %s or remote repository not found: %v", repoName, err)
+	case trustpinning.ErrRootRotationFail, trustpinning.ErrValidationFail, signed.ErrInvalidKeyType:
+		return newError(ErrPotentialAttack, repoName, err, "Warning: potential malicious behavior - trust data mismatch for remote repository %s: %v", repoName, err)
+	case signed.ErrNoKeys:
+		return newError(ErrKeyNotFound, repoName, err, "Error: could not find signing keys for remote repository %s, or could not decrypt signing key: %v", repoName, err)
+	case signed.ErrLowVersion:
+		return newError(ErrPotentialAttack, repoName, err, "Warning: potential malicious behavior - trust data version is lower than expected for remote repository %s: %v", repoName, err)
+	case signed.ErrRoleThreshold:
+		return newError(ErrPotentialAttack, repoName, err, "Warning: potential malicious behavior - trust data has insufficient signatures for remote repository %s: %v", repoName, err)
+	case client.ErrRepositoryNotExist:
+		return newError(ErrNoTrustData, repoName, err, "Error: remote trust data does not exist for %s: %v", repoName, err)
+	case signed.ErrInsufficientSignatures:
+		return newError(ErrKeyNotFound, repoName, err, "Error: could not produce valid signature for %s.  If Yubikey was used, was touch input provided?: %v", repoName, err)
+	case client.ErrNoSuchTarget:
+		return newError(ErrNoSuchTarget, repoName, err, "Error: remote trust target does not exist for %s: %v", repoName, err)
+	}
+
+	return err
+}
+
+// connectionError classifies err, the failure to connect and authenticate
+// to the notary server of repoName, as ErrPotentialAttack when the
+// certificate of the server could not be verified, and as ErrNetwork when
+// the server could not be reached or failed to answer. Other errors are
+// returned unchanged.
+func connectionError(repoName string, err error) error {
+	var (
+		netErr       net.Error
+		transportErr *transport.Error
+	)
+	switch {
+	case certificateError(err):
+		return untrustedServerError(repoName, err)
+	case errors.As(err, &netErr):
+	case errors.As(err, &transportErr) && transportErr.StatusCode >= http.StatusInternalServerError:
+	default:
+		return err
+	}
+	return newError(ErrNetwork, repoName, err, "Error: error contacting notary server: %v", err)
+}
+
+// certificateError reports whether err is the failure to verify the
+// certificate of a server, which may be spoofed.
+func certificateError(err error) bool {
+	var (
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+	)
+	return errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
+
+func untrustedServerError(repoName string, err error) error {
+	return newError(ErrPotentialAttack, repoName, err, "Warning: potential malicious behavior - the certificate of the notary server of %s could not be verified: %v", repoName, err)
+}
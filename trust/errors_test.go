@@ -0,0 +1,104 @@
+package trust
+
+import (
+	"context"
+	"crypto/x509"
+	stderrors "errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/pkg/errors"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/storage"
+	"github.com/theupdateframework/notary/trustpinning"
+	"github.com/theupdateframework/notary/tuf/signed"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestNotaryErrorKinds(t *testing.T) {
+	cases := []struct {
+		err  error
+		kind error
+	}{
+		{err: client.ErrRepositoryNotExist{}, kind: ErrNoTrustData},
+		{err: storage.ErrMetaNotFound{Resource: "root"}, kind: ErrNoTrustData},
+		{err: client.ErrNoSuchTarget("latest"), kind: ErrNoSuchTarget},
+		{err: signed.ErrExpired{Role: "targets"}, kind: ErrExpired},
+		{err: signed.ErrNoKeys{}, kind: ErrKeyNotFound},
+		{err: storage.NetworkError{Wrapped: stderrors.New("connection refused")}, kind: ErrNetwork},
+		{err: storage.ErrOffline{}, kind: ErrNetwork},
+		{err: trustpinning.ErrValidationFail{Reason: "bad root"}, kind: ErrPotentialAttack},
+		{err: signed.ErrLowVersion{Actual: 1, Current: 2}, kind: ErrPotentialAttack},
+	}
+	for _, c := range cases {
+		err := errors.Wrap(NotaryError("example.com/foo", c.err), "failed")
+		assert.Check(t, stderrors.Is(err, c.kind), "%T", c.err)
+
+		var notaryErr *Error
+		assert.Assert(t, stderrors.As(err, &notaryErr))
+		assert.Check(t, is.Equal(notaryErr.Repository, "example.com/foo"))
+		assert.Check(t, is.Equal(notaryErr.Err.Error(), c.err.Error()))
+	}
+}
+
+func TestNotaryErrorAsNotaryType(t *testing.T) {
+	err := NotaryError("example.com/foo", client.ErrNoSuchTarget("latest"))
+	assert.Error(t, err, "Error: remote trust target does not exist for example.com/foo: No valid trust data for latest")
+
+	var target client.ErrNoSuchTarget
+	assert.Assert(t, stderrors.As(err, &target))
+	assert.Check(t, is.Equal(string(target), "latest"))
+	assert.Check(t, !stderrors.Is(err, ErrNetwork))
+}
+
+func TestNotaryErrorUnknown(t *testing.T) {
+	err := stderrors.New("unknown")
+	assert.Check(t, is.Equal(NotaryError("example.com/foo", err), err))
+}
+
+func TestConnectionError(t *testing.T) {
+	unavailable := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	closed := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+
+	ref, _ := name.ParseReference("example.com/foo/image:latest", name.WeakValidation)
+	repoInfo := ref.Context().Registry
+	for _, server := range []*httptest.Server{closed, unavailable} {
+		config := &Config{InMemory: true, ServerUrl: server.URL, Transport: unavailable.Client().Transport}
+		_, err := GetReadOnlyNotaryRepository(context.Background(), ref, nil, &repoInfo, config)
+		assert.Check(t, stderrors.Is(err, ErrNetwork), "%s: %v", server.URL, err)
+		assert.Check(t, !stderrors.Is(err, ErrNoTrustData))
+	}
+
+	err := stderrors.New("unsupported challenge")
+	assert.Check(t, is.Equal(connectionError("example.com/foo", err), err))
+}
+
+func TestConnectionErrorCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	ref, _ := name.ParseReference("example.com/foo/image:latest", name.WeakValidation)
+	repoInfo := ref.Context().Registry
+	// the certificate of the server is not trusted; the server is not named
+	// localhost so that it is not also tried over http
+	dial := func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, server.Listener.Addr().String())
+	}
+	config := &Config{InMemory: true, ServerUrl: "https://notary.test", Transport: &http.Transport{DialContext: dial}}
+	_, err := GetReadOnlyNotaryRepository(context.Background(), ref, nil, &repoInfo, config)
+	assert.Check(t, stderrors.Is(err, ErrPotentialAttack), "%v", err)
+	assert.Check(t, !stderrors.Is(err, ErrNetwork))
+
+	err = NotaryError("example.com/foo", storage.NetworkError{Wrapped: &url.Error{Op: "Get", URL: server.URL, Err: x509.HostnameError{Certificate: server.Certificate(), Host: "example.com"}}})
+	assert.Check(t, stderrors.Is(err, ErrPotentialAttack), "%v", err)
+	assert.Check(t, stderrors.Is(NotaryError("example.com/foo", storage.NetworkError{Wrapped: stderrors.New("connection refused")}), ErrNetwork))
+}
@@ -1,14 +1,27 @@
 package trust
 
 import (
+	"context"
+
+	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/theupdateframework/notary/client"
 )
 
 type TrustedRepository interface {
-	ListTarget() ([]*client.Target, error)
+	ListTarget() ([]*client.TargetWithRole, error)
 	Verify() (*client.Target, error)
-	TrustPush(img v1.Image) error
-	SignImage(img v1.Image) error
-	RevokeTag(tag string) error
+	TrustPush(img v1.Image) (*SignResult, error)
+	SignImage(img v1.Image) (*SignResult, error)
+	RevokeTag(tag string) (*RevokeResult, error)
+
+	ListTargetContext(ctx context.Context) ([]*client.TargetWithRole, error)
+	VerifyContext(ctx context.Context) (*client.Target, error)
+	TrustPushContext(ctx context.Context, img v1.Image) (*SignResult, error)
+	SignImageContext(ctx context.Context, img v1.Image) (*SignResult, error)
+	RevokeTagContext(ctx context.Context, tag string) (*RevokeResult, error)
+
+	VerifyImage(ctx context.Context) (name.Digest, *client.Target, error)
+	TrustPull(ctx context.Context) (v1.Image, *client.TargetWithRole, error)
+	SignImageTags(ctx context.Context, img v1.Image, tags []string) ([]*SignResult, error)
 }
@@ -0,0 +1,192 @@
+package trust
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/trustmanager"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/signed"
+	"github.com/theupdateframework/notary/tuf/utils"
+)
+
+// rsaKeyBits is the size of the RSA keys generated by GenerateKey.
+const rsaKeyBits = 4096
+
+// KeyInfo describes a signing key held by a CryptoService.
+type KeyInfo struct {
+	ID   string
+	Role data.RoleName
+	GUN  data.GUN
+}
+
+// keyInfoService is implemented by CryptoServices able to tell which GUN a
+// key belongs to, such as the ones returned by GetCryptoService.
+type keyInfoService interface {
+	GetKeyInfo(keyID string) (trustmanager.KeyInfo, error)
+}
+
+// GetCryptoService returns the CryptoService holding the signing keys of
+// the trust directory of config, which are unlocked with the configured
+// passphrase retriever, or config.CryptoService when it is set, as it must
+// be in in-memory mode. The keys notary generates use the configured key
+// algorithm.
+func GetCryptoService(config *Config) (signed.CryptoService, error) {
+	var cs signed.CryptoService
+	switch {
+	case config.CryptoService != nil:
+		cs = config.CryptoService
+	case config.InMemory:
+		// a new key store would drop the keys added to it after each use
+		return nil, errors.New("in-memory mode requires a CryptoService, e.g. from NewMemoryCryptoService")
+	default:
+		var err error
+		cs, err = newPassphraseService(config.passphraseRetriever(), func(retriever notary.PassRetriever) (trustmanager.KeyStore, error) {
+			return trustmanager.NewKeyFileStore(getTrustDirectory(config.RootPath), retriever)
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to open private key store")
+		}
+	}
+	if config.KeyAlgorithm != "" && config.KeyAlgorithm != data.ECDSAKey {
+		cs = keyAlgorithmService{CryptoService: cs, algorithm: config.KeyAlgorithm}
+	}
+	return cs, nil
+}
+
+// NewMemoryCryptoService returns a CryptoService holding its keys in memory,
+// which are unlocked with the passphrase retriever of config. Reuse it as
+// the CryptoService of an in-memory Config for the keys to outlive a
+// single operation.
+func NewMemoryCryptoService(config *Config) signed.CryptoService {
+	cs, _ := newPassphraseService(config.passphraseRetriever(), func(retriever notary.PassRetriever) (trustmanager.KeyStore, error) {
+		return trustmanager.NewKeyMemoryStore(retriever), nil
+	})
+	return cs
+}
+
+// ListKeys returns the keys of cs for role, or for every role if it is
+// empty, sorted by ID. If gun is not empty, only the keys of the
+// repository gun and the keys which are not bound to a repository, such as
+// root and delegation keys, are returned.
+func ListKeys(cs signed.CryptoService, role data.RoleName, gun data.GUN) []KeyInfo {
+	var keys []KeyInfo
+	for keyID, keyRole := range cs.ListAllKeys() {
+		if role != "" && keyRole != role {
+			continue
+		}
+		key := KeyInfo{ID: keyID, Role: keyRole}
+		if kis, ok := cs.(keyInfoService); ok {
+			if info, err := kis.GetKeyInfo(keyID); err == nil {
+				key.GUN = info.Gun
+			}
+		}
+		if gun != "" && key.GUN != "" && key.GUN != gun {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// GenerateKey creates a key using algorithm, one of data.ECDSAKey,
+// data.ED25519Key or data.RSAKey, for role in the repository gun, and adds
+// it to cs.
+func GenerateKey(cs signed.CryptoService, role data.RoleName, gun data.GUN, algorithm string) (data.PublicKey, error) {
+	if algorithm != data.RSAKey {
+		return cs.Create(role, gun, algorithm)
+	}
+
+	// Notary only generates elliptic keys, RSA keys have to be imported.
+	rsaKey, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate rsa key")
+	}
+	privKey, err := utils.RSAToPrivateKey(rsaKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := cs.AddKey(role, gun, privKey); err != nil {
+		return nil, err
+	}
+	return data.PublicKeyFromPrivate(privKey), nil
+}
+
+// ImportKey adds the PEM encoded private key to cs. role and gun annotate
+// the key; when empty, the role and gun headers of the PEM block are used
+// instead. An encrypted key is decrypted with a passphrase asked from the
+// passphrase retriever of config.
+func ImportKey(cs signed.CryptoService, pemBytes []byte, role data.RoleName, gun data.GUN, config *Config) (data.PublicKey, error) {
+	pemRole, pemGUN, err := utils.ExtractPrivateKeyAttributes(pemBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read private key")
+	}
+	if role == "" {
+		role = pemRole
+	}
+	if gun == "" {
+		gun = pemGUN
+	}
+	if role == "" {
+		return nil, errors.New("no role given for the private key")
+	}
+
+	privKey, err := utils.ParsePEMPrivateKey(pemBytes, "")
+	if err != nil {
+		passphrases := &passphraseRecorder{retriever: config.passphraseRetriever()}
+		privKey, _, err = trustmanager.GetPasswdDecryptBytes(passphrases.retrieve, pemBytes, "imported", role.String())
+		if err = passphrases.cause(err); err != nil {
+			return nil, errors.Wrap(err, "failed to decrypt private key")
+		}
+	}
+	if err := cs.AddKey(role, gun, privKey); err != nil {
+		return nil, err
+	}
+	return data.PublicKeyFromPrivate(privKey), nil
+}
+
+// ExportPrivateKey returns the private key keyID of cs in PEM encoded
+// PKCS#8 format, annotated with its role and gun and encrypted with
+// passphrase.
+func ExportPrivateKey(cs signed.CryptoService, keyID string, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("a passphrase is required to export a private key")
+	}
+	privKey, role, err := cs.GetPrivateKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	var gun data.GUN
+	if kis, ok := cs.(keyInfoService); ok {
+		if info, err := kis.GetKeyInfo(keyID); err == nil {
+			gun = info.Gun
+		}
+	}
+	return utils.ConvertPrivateKeyToPKCS8(privKey, role, gun, passphrase)
+}
+
+// ExportPublicKey returns the public key keyID of cs in PEM encoded PKIX
+// format, which ParsePublicKey reads back.
+func ExportPublicKey(cs signed.CryptoService, keyID string) ([]byte, error) {
+	pubKey := cs.GetKey(keyID)
+	if pubKey == nil {
+		return nil, trustmanager.ErrKeyNotFound{KeyID: keyID}
+	}
+
+	der := pubKey.Public()
+	if pubKey.Algorithm() == data.ED25519Key {
+		var err error
+		der, err = x509.MarshalPKIXPublicKey(ed25519.PublicKey(pubKey.Public()))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
@@ -0,0 +1,124 @@
+package trust
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/theupdateframework/notary/passphrase"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/signed"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func testKeyConfig(t *testing.T) (*Config, func()) {
+	tmpDir, err := ioutil.TempDir("", "notary-test-")
+	assert.NilError(t, err)
+	config := &Config{RootPath: tmpDir, PassRetriever: passphrase.ConstantRetriever("password")}
+	return config, func() { os.RemoveAll(tmpDir) }
+}
+
+func TestGenerateAndListKeys(t *testing.T) {
+	config, cleanup := testKeyConfig(t)
+	defer cleanup()
+	cs, err := GetCryptoService(config)
+	assert.NilError(t, err)
+
+	rootKey, err := GenerateKey(cs, data.CanonicalRootRole, "", data.ECDSAKey)
+	assert.NilError(t, err)
+	targetsKey, err := GenerateKey(cs, data.CanonicalTargetsRole, "example.com/foo", data.ED25519Key)
+	assert.NilError(t, err)
+	_, err = GenerateKey(cs, data.CanonicalTargetsRole, "example.com/bar", data.ECDSAKey)
+	assert.NilError(t, err)
+
+	keys := ListKeys(cs, data.CanonicalRootRole, "")
+	assert.Check(t, is.DeepEqual(keys, []KeyInfo{{ID: rootKey.ID(), Role: data.CanonicalRootRole}}))
+
+	keys = ListKeys(cs, data.CanonicalTargetsRole, "example.com/foo")
+	assert.Check(t, is.DeepEqual(keys, []KeyInfo{{ID: targetsKey.ID(), Role: data.CanonicalTargetsRole, GUN: "example.com/foo"}}))
+
+	assert.Check(t, is.Len(ListKeys(cs, "", "example.com/foo"), 2))
+	assert.Check(t, is.Len(ListKeys(cs, "", ""), 3))
+}
+
+func TestExportAndImportKeys(t *testing.T) {
+	config, cleanup := testKeyConfig(t)
+	defer cleanup()
+	cs, err := GetCryptoService(config)
+	assert.NilError(t, err)
+
+	for _, algorithm := range []string{data.ECDSAKey, data.ED25519Key} {
+		pubKey, err := GenerateKey(cs, data.CanonicalTargetsRole, "example.com/foo", algorithm)
+		assert.NilError(t, err)
+
+		_, err = ExportPrivateKey(cs, pubKey.ID(), "")
+		assert.Error(t, err, "a passphrase is required to export a private key")
+		privPEM, err := ExportPrivateKey(cs, pubKey.ID(), "export")
+		assert.NilError(t, err)
+
+		pubPEM, err := ExportPublicKey(cs, pubKey.ID())
+		assert.NilError(t, err)
+		parsed, err := ParsePublicKey(pubPEM)
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(parsed.ID(), pubKey.ID()))
+
+		importConfig, importCleanup := testKeyConfig(t)
+		defer importCleanup()
+		importConfig.PassRetriever = passphrase.ConstantRetriever("export")
+		importCS, err := GetCryptoService(importConfig)
+		assert.NilError(t, err)
+		imported, err := ImportKey(importCS, privPEM, "", "", importConfig)
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(imported.ID(), pubKey.ID()))
+		assert.Check(t, is.DeepEqual(ListKeys(importCS, "", ""), []KeyInfo{{ID: pubKey.ID(), Role: data.CanonicalTargetsRole, GUN: "example.com/foo"}}))
+	}
+
+	_, err = ExportPublicKey(cs, "missing")
+	assert.Error(t, err, "signing key not found: missing")
+}
+
+func TestCryptoServiceKeyAlgorithm(t *testing.T) {
+	config, cleanup := testKeyConfig(t)
+	defer cleanup()
+	config.KeyAlgorithm = data.ED25519Key
+	cs, err := GetCryptoService(config)
+	assert.NilError(t, err)
+
+	targetsKey, err := cs.Create(data.CanonicalTargetsRole, "example.com/foo", data.ECDSAKey)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(targetsKey.Algorithm(), data.ED25519Key))
+
+	// root keys need a certificate, which ed25519 keys cannot sign
+	rootKey, err := cs.Create(data.CanonicalRootRole, "", data.ECDSAKey)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(rootKey.Algorithm(), data.ECDSAKey))
+}
+
+func TestCryptoServicePassphraseRequired(t *testing.T) {
+	config, cleanup := testKeyConfig(t)
+	defer cleanup()
+	cs, err := GetCryptoService(config)
+	assert.NilError(t, err)
+	key, err := GenerateKey(cs, data.CanonicalTargetsRole, "example.com/foo", data.ECDSAKey)
+	assert.NilError(t, err)
+	exported, err := ExportPrivateKey(cs, key.ID(), "password")
+	assert.NilError(t, err)
+
+	config.PassRetriever = nil
+	config.NonInteractive = true
+	cs, err = GetCryptoService(config)
+	assert.NilError(t, err)
+
+	_, err = GenerateKey(cs, data.CanonicalTargetsRole, "example.com/bar", data.ECDSAKey)
+	assert.Check(t, is.ErrorType(err, ErrPassphraseRequired{}))
+	_, err = ImportKey(cs, exported, "", "", config)
+	assert.Check(t, is.ErrorType(errors.Cause(err), ErrPassphraseRequired{}))
+
+	// signing, as when publishing, needs the key to be decrypted
+	s, err := data.NewTargets().ToSigned()
+	assert.NilError(t, err)
+	err = signed.Sign(cs, s, []data.PublicKey{key}, 1, nil)
+	assert.Check(t, is.ErrorType(err, ErrPassphraseRequired{}))
+}
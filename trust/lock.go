@@ -0,0 +1,127 @@
+package trust
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// lockRetryInterval is the interval at which a busy trust data lock is
+// tried again.
+const lockRetryInterval = 50 * time.Millisecond
+
+// errLockBusy is returned by tryLockFile when another process holds the
+// lock.
+var errLockBusy = errors.New("lock busy")
+
+// gunLocks serializes within the process the reads of the trust data of a
+// GUN with its changes, which notary's file store does not make atomic, by
+// trust data directory.
+var gunLocks = struct {
+	sync.Mutex
+	locks map[string]*gunLock
+}{locks: make(map[string]*gunLock)}
+
+type gunLock struct {
+	sync.RWMutex
+	refs int
+}
+
+// lockGUN takes the lock of the trust data of gun in the trust directory of
+// config and returns the function releasing it. Unless readOnly, the lock
+// is exclusive and also taken in the trust directory, which serializes the
+// changes to the changelist and their publication across processes; that
+// lock is waited for until ctx is done. Within the process, readers are
+// serialized with the changes. In memory there is nothing to lock.
+func lockGUN(ctx context.Context, config *Config, gun data.GUN, readOnly bool) (unlock func(), err error) {
+	if config.InMemory {
+		return func() {}, nil
+	}
+	gunDir, err := filepath.Abs(filepath.Join(getTrustDirectory(config.RootPath), tufDir, filepath.FromSlash(gun.String())))
+	if err != nil {
+		return nil, err
+	}
+	if readOnly {
+		return lockInProcess(gunDir, true), nil
+	}
+
+	unlockFile, err := lockGUNFile(ctx, gunDir, gun)
+	if err != nil {
+		return nil, err
+	}
+	unlockInProcess := lockInProcess(gunDir, false)
+	return func() {
+		unlockInProcess()
+		unlockFile()
+	}, nil
+}
+
+// lockInProcess takes the in-process lock of the trust data in gunDir,
+// shared if readOnly, and returns the function releasing it.
+func lockInProcess(gunDir string, readOnly bool) (unlock func()) {
+	gunLocks.Lock()
+	l, ok := gunLocks.locks[gunDir]
+	if !ok {
+		l = &gunLock{}
+		gunLocks.locks[gunDir] = l
+	}
+	l.refs++
+	gunLocks.Unlock()
+
+	if readOnly {
+		l.RLock()
+	} else {
+		l.Lock()
+	}
+	return func() {
+		if readOnly {
+			l.RUnlock()
+		} else {
+			l.Unlock()
+		}
+		gunLocks.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(gunLocks.locks, gunDir)
+		}
+		gunLocks.Unlock()
+	}
+}
+
+// lockGUNFile takes the lock file of the trust data of gun in gunDir,
+// waiting for it until ctx is done.
+func lockGUNFile(ctx context.Context, gunDir string, gun data.GUN) (unlock func(), err error) {
+	if err := os.MkdirAll(gunDir, 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(gunDir, "lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		err = tryLockFile(f)
+		if err != errLockBusy {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-time.After(lockRetryInterval):
+			continue
+		}
+		break
+	}
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "failed to lock trust data of %s", gun)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,18 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package trust
+
+import (
+	"os"
+)
+
+// tryLockFile does not lock on platforms without file locking; changes to
+// the trust data are then only serialized within a process.
+func tryLockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,83 @@
+package trust
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestLockGUN(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "notary-test-")
+	assert.NilError(t, err)
+	defer os.RemoveAll(tmpDir)
+	config := &Config{RootPath: tmpDir}
+
+	unlock, err := lockGUN(context.Background(), config, "example.com/foo", false)
+	assert.NilError(t, err)
+
+	// another GUN is not locked
+	unlockOther, err := lockGUN(context.Background(), config, "example.com/foo/bar", false)
+	assert.NilError(t, err)
+	unlockOther()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*lockRetryInterval)
+	defer cancel()
+	_, err = lockGUN(ctx, config, "example.com/foo", false)
+	assert.ErrorContains(t, err, context.DeadlineExceeded.Error())
+
+	go func() {
+		time.Sleep(lockRetryInterval)
+		unlock()
+	}()
+	relock, err := lockGUN(context.Background(), config, "example.com/foo", false)
+	assert.NilError(t, err)
+	relock()
+}
+
+func TestLockGUNReaders(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "notary-test-")
+	assert.NilError(t, err)
+	defer os.RemoveAll(tmpDir)
+	config := &Config{RootPath: tmpDir}
+
+	// readers share the trust data
+	unlockReader, err := lockGUN(context.Background(), config, "example.com/foo", true)
+	assert.NilError(t, err)
+	unlockOther, err := lockGUN(context.Background(), config, "example.com/foo", true)
+	assert.NilError(t, err)
+	unlockOther()
+
+	// a writer waits for the readers, and the readers for the writer
+	locked := make(chan func())
+	go func() {
+		unlock, err := lockGUN(context.Background(), config, "example.com/foo", false)
+		assert.Check(t, err)
+		locked <- unlock
+	}()
+	select {
+	case <-locked:
+		t.Fatal("writer locked the trust data of a reader")
+	case <-time.After(3 * lockRetryInterval):
+	}
+	unlockReader()
+	unlockWriter := <-locked
+
+	go func() {
+		unlock, err := lockGUN(context.Background(), config, "example.com/foo", true)
+		assert.Check(t, err)
+		locked <- unlock
+	}()
+	select {
+	case <-locked:
+		t.Fatal("reader locked the trust data of a writer")
+	case <-time.After(3 * lockRetryInterval):
+	}
+	unlockWriter()
+	(<-locked)()
+
+	assert.Equal(t, len(gunLocks.locks), 0)
+}
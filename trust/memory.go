@@ -0,0 +1,152 @@
+package trust
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/storage"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// MemoryCache caches the trust data of repositories in memory, in place of
+// the trust directory, for a Config with InMemory set. It is safe for
+// concurrent use.
+type MemoryCache struct {
+	maxSize int64
+	ttl     time.Duration
+	now     func() time.Time
+
+	mu      sync.Mutex
+	size    int64
+	entries map[string]*list.Element
+	// lru orders the entries from the most to the least recently used.
+	lru *list.List
+}
+
+type memoryCacheEntry struct {
+	key   string
+	blob  []byte
+	added time.Time
+}
+
+// NewMemoryCache returns a MemoryCache holding at most maxSize bytes of
+// metadata, evicting the least recently used first, and refetching metadata
+// cached for longer than ttl. Zero values disable the bounds.
+func NewMemoryCache(maxSize int64, ttl time.Duration) *MemoryCache {
+	return &MemoryCache{
+		maxSize: maxSize,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// store returns the metadata store of gun.
+func (c *MemoryCache) store(gun data.GUN) storage.MetadataStore {
+	// '#' cannot appear in a GUN, so no prefix is a prefix of another
+	return memoryCacheStore{cache: c, prefix: gun.String() + "#"}
+}
+
+func (c *MemoryCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*memoryCacheEntry)
+	if c.ttl > 0 && c.now().Sub(entry.added) > c.ttl {
+		c.removeElement(e)
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return entry.blob, true
+}
+
+func (c *MemoryCache) set(key string, blob []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.removeElement(e)
+	}
+	if c.maxSize > 0 && int64(len(blob)) > c.maxSize {
+		return
+	}
+	entry := &memoryCacheEntry{key: key, blob: append([]byte(nil), blob...), added: c.now()}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.size += int64(len(blob))
+	for c.maxSize > 0 && c.size > c.maxSize {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+func (c *MemoryCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.removeElement(e)
+	}
+}
+
+func (c *MemoryCache) removePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(e)
+		}
+	}
+}
+
+func (c *MemoryCache) removeElement(e *list.Element) {
+	entry := c.lru.Remove(e).(*memoryCacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= int64(len(entry.blob))
+}
+
+// memoryCacheStore is the storage.MetadataStore of a repository in a
+// MemoryCache.
+type memoryCacheStore struct {
+	cache  *MemoryCache
+	prefix string
+}
+
+func (s memoryCacheStore) GetSized(name string, size int64) ([]byte, error) {
+	blob, ok := s.cache.get(s.prefix + name)
+	if !ok {
+		return nil, storage.ErrMetaNotFound{Resource: name}
+	}
+	if size == storage.NoSizeLimit {
+		size = notary.MaxDownloadSize
+	}
+	if int64(len(blob)) > size {
+		blob = blob[:size]
+	}
+	return blob, nil
+}
+
+func (s memoryCacheStore) Set(name string, blob []byte) error {
+	s.cache.set(s.prefix+name, blob)
+	return nil
+}
+
+func (s memoryCacheStore) SetMulti(blobs map[string][]byte) error {
+	for name, blob := range blobs {
+		s.cache.set(s.prefix+name, blob)
+	}
+	return nil
+}
+
+func (s memoryCacheStore) Remove(name string) error {
+	s.cache.remove(s.prefix + name)
+	return nil
+}
+
+func (s memoryCacheStore) RemoveAll() error {
+	s.cache.removePrefix(s.prefix)
+	return nil
+}
@@ -0,0 +1,95 @@
+package trust
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/passphrase"
+	"github.com/theupdateframework/notary/storage"
+	"github.com/theupdateframework/notary/tuf/data"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestMemoryCacheStores(t *testing.T) {
+	cache := NewMemoryCache(0, 0)
+	foo := cache.store("example.com/foo")
+	fooBar := cache.store("example.com/foo/bar")
+
+	assert.NilError(t, foo.SetMulti(map[string][]byte{"root": []byte("foo root"), "targets/releases": []byte("foo releases")}))
+	assert.NilError(t, fooBar.Set("root", []byte("foo/bar root")))
+
+	blob, err := foo.GetSized("targets/releases", storage.NoSizeLimit)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(blob), "foo releases"))
+	blob, err = foo.GetSized("root", 3)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(blob), "foo"))
+
+	assert.NilError(t, foo.RemoveAll())
+	_, err = foo.GetSized("root", storage.NoSizeLimit)
+	assert.Check(t, is.ErrorType(err, storage.ErrMetaNotFound{}))
+	blob, err = fooBar.GetSized("root", storage.NoSizeLimit)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(string(blob), "foo/bar root"))
+}
+
+func TestMemoryCacheBounds(t *testing.T) {
+	now := time.Now()
+	cache := NewMemoryCache(10, time.Minute)
+	cache.now = func() time.Time { return now }
+	store := cache.store("example.com/foo")
+
+	assert.NilError(t, store.Set("root", []byte("12345")))
+	assert.NilError(t, store.Set("targets", []byte("12345")))
+	_, err := store.GetSized("root", storage.NoSizeLimit)
+	assert.NilError(t, err)
+
+	// targets is the least recently used entry
+	assert.NilError(t, store.Set("snapshot", []byte("123")))
+	_, err = store.GetSized("targets", storage.NoSizeLimit)
+	assert.Check(t, is.ErrorType(err, storage.ErrMetaNotFound{}))
+	assert.Check(t, is.Equal(cache.size, int64(8)))
+
+	now = now.Add(2 * time.Minute)
+	_, err = store.GetSized("root", storage.NoSizeLimit)
+	assert.Check(t, is.ErrorType(err, storage.ErrMetaNotFound{}))
+}
+
+func TestInMemoryRepository(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "notary-test-")
+	assert.NilError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	config := &Config{RootPath: tmpDir, InMemory: true, MemoryCache: NewMemoryCache(0, 0), PassRetriever: passphrase.ConstantRetriever("password")}
+	_, err = GetCryptoService(config)
+	assert.Check(t, is.ErrorContains(err, "requires a CryptoService"))
+
+	config.CryptoService = NewMemoryCryptoService(config)
+	cs, err := GetCryptoService(config)
+	assert.NilError(t, err)
+	key, err := GenerateKey(cs, data.CanonicalTargetsRole, "example.com/foo", data.ECDSAKey)
+	assert.NilError(t, err)
+
+	ref, _ := name.ParseReference("example.com/foo:latest", name.WeakValidation)
+	notaryRepo, err := newRepository(config, GUN(ref), "https://notary.example.com", nil)
+	assert.NilError(t, err)
+	// the repository signs with the keys of the Config
+	assert.Check(t, notaryRepo.GetCryptoService().GetKey(key.ID()) != nil)
+	cl, err := notaryRepo.GetChangelist()
+	assert.NilError(t, err)
+	assert.NilError(t, notaryRepo.AddTarget(&client.Target{Name: "latest", Hashes: data.Hashes{"sha256": make([]byte, 32)}, Length: 1}, data.CanonicalTargetsRole))
+	assert.Check(t, is.Len(cl.List(), 1))
+
+	files, err := ioutil.ReadDir(tmpDir)
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(files, 0))
+
+	cs, err = GetCryptoService(config)
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(ListKeys(cs, "", ""), 1))
+}
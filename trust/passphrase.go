@@ -0,0 +1,259 @@
+package trust
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/theupdateframework/notary"
+	"github.com/theupdateframework/notary/cryptoservice"
+	"github.com/theupdateframework/notary/trustmanager"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/signed"
+)
+
+const (
+	// DefaultPassphraseEnvPrefix is the prefix of the environment variables
+	// read by EnvPassphraseRetriever, matching the notary CLI.
+	DefaultPassphraseEnvPrefix = "NOTARY"
+
+	delegationAlias = "delegation"
+	defaultAlias    = "default"
+)
+
+// ErrPassphraseRequired is returned by non-interactive passphrase retrievers
+// when none of their sources holds a passphrase for the requested key.
+type ErrPassphraseRequired struct {
+	KeyName string
+	Alias   string
+}
+
+func (err ErrPassphraseRequired) Error() string {
+	return fmt.Sprintf("passphrase required for %s key %s, but none was provided", err.Alias, err.KeyName)
+}
+
+// passphraseAlias maps the alias a key is requested with to the name used
+// to look up its passphrase: delegation roles all share "delegation".
+func passphraseAlias(alias string) string {
+	if strings.Contains(alias, "/") {
+		return delegationAlias
+	}
+	return alias
+}
+
+// giveUp reports whether a retriever returning a fixed passphrase should
+// stop retrying, the passphrase having already been refused.
+func giveUp(numAttempts int) bool {
+	return numAttempts > 1
+}
+
+// GetNonInteractivePassphraseRetriever is like GetPassphraseRetriever, but
+// returns ErrPassphraseRequired instead of prompting for a passphrase missing
+// from the configuration.
+func GetNonInteractivePassphraseRetriever(rootPassphrase string, repoPassphrase string) notary.PassRetriever {
+	return configPassphraseRetriever(rootPassphrase, repoPassphrase, missingPassphrase)
+}
+
+// passphraseRecorder wraps a passphrase retriever to remember the
+// ErrPassphraseRequired it returns, which notary replaces with
+// trustmanager.ErrPasswordInvalid or trustmanager.ErrAttemptsExceeded.
+type passphraseRecorder struct {
+	retriever notary.PassRetriever
+	err       error
+}
+
+func (r *passphraseRecorder) retrieve(keyName string, alias string, createNew bool, numAttempts int) (string, bool, error) {
+	v, giveup, err := r.retriever(keyName, alias, createNew, numAttempts)
+	if _, ok := err.(ErrPassphraseRequired); ok {
+		r.err = err
+	}
+	return v, giveup, err
+}
+
+// cause returns the ErrPassphraseRequired recorded since the last call in
+// place of err, if err is not nil, and err otherwise.
+func (r *passphraseRecorder) cause(err error) error {
+	if err != nil && r.err != nil {
+		err = r.err
+	}
+	r.err = nil
+	return err
+}
+
+func missingPassphrase(keyName string, alias string, createNew bool, numAttempts int) (string, bool, error) {
+	return "", true, ErrPassphraseRequired{KeyName: keyName, Alias: alias}
+}
+
+// EnvPassphraseRetriever returns a passphrase retriever reading the
+// passphrase of each role from the environment variable
+// ${prefix}_${ROLE}_PASSPHRASE, e.g. NOTARY_ROOT_PASSPHRASE or
+// NOTARY_DELEGATION_PASSPHRASE. Non-root roles fall back to
+// ${prefix}_REPOSITORY_PASSPHRASE.
+func EnvPassphraseRetriever(prefix string) notary.PassRetriever {
+	if prefix == "" {
+		prefix = DefaultPassphraseEnvPrefix
+	}
+	lookup := func(alias string) string {
+		return os.Getenv(fmt.Sprintf("%s_%s_PASSPHRASE", prefix, strings.ToUpper(alias)))
+	}
+
+	return func(keyName string, alias string, createNew bool, numAttempts int) (string, bool, error) {
+		if v := lookup(passphraseAlias(alias)); v != "" {
+			return v, giveUp(numAttempts), nil
+		}
+		if v := lookup("repository"); v != "" && alias != data.CanonicalRootRole.String() {
+			return v, giveUp(numAttempts), nil
+		}
+		return missingPassphrase(keyName, alias, createNew, numAttempts)
+	}
+}
+
+// FilePassphraseRetriever returns a passphrase retriever reading the
+// passphrase of each role from a file. files maps "root", "targets",
+// "snapshot" or "delegation" to the path of the file holding its
+// passphrase; non-root roles fall back to the "default" entry. A single
+// trailing newline is stripped from the file content.
+func FilePassphraseRetriever(files map[string]string) notary.PassRetriever {
+	read := func(alias string) (string, error) {
+		path, ok := files[alias]
+		if !ok {
+			return "", nil
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		return trimNewline(string(content)), nil
+	}
+
+	return func(keyName string, alias string, createNew bool, numAttempts int) (string, bool, error) {
+		v, err := read(passphraseAlias(alias))
+		if err == nil && v == "" && alias != data.CanonicalRootRole.String() {
+			v, err = read(defaultAlias)
+		}
+		if err != nil {
+			return "", true, err
+		}
+		if v == "" {
+			return missingPassphrase(keyName, alias, createNew, numAttempts)
+		}
+		return v, giveUp(numAttempts), nil
+	}
+}
+
+// passphraseRequest is written as JSON to the standard input of a
+// passphrase helper command.
+type passphraseRequest struct {
+	KeyName   string `json:"key_name"`
+	Alias     string `json:"alias"`
+	CreateNew bool   `json:"create_new"`
+	Attempts  int    `json:"attempts"`
+}
+
+// CommandPassphraseRetriever returns a passphrase retriever delegating to an
+// external helper, in the manner of docker credential helpers. The command
+// is run with args for every request, receives the request as a JSON object
+// with "key_name", "alias", "create_new" and "attempts" on its standard
+// input, and prints the passphrase on its standard output. Printing nothing
+// means the helper has no passphrase for the key.
+func CommandPassphraseRetriever(command string, args ...string) notary.PassRetriever {
+	return func(keyName string, alias string, createNew bool, numAttempts int) (string, bool, error) {
+		request, err := json.Marshal(passphraseRequest{
+			KeyName:   keyName,
+			Alias:     alias,
+			CreateNew: createNew,
+			Attempts:  numAttempts,
+		})
+		if err != nil {
+			return "", true, err
+		}
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(command, args...)
+		cmd.Stdin = bytes.NewReader(request)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return "", true, fmt.Errorf("passphrase helper %s failed: %v: %s", command, err, strings.TrimSpace(stderr.String()))
+		}
+
+		v := trimNewline(stdout.String())
+		if v == "" {
+			return missingPassphrase(keyName, alias, createNew, numAttempts)
+		}
+		return v, giveUp(numAttempts), nil
+	}
+}
+
+// ChainPassphraseRetrievers returns a passphrase retriever asking each of
+// retrievers in turn, moving on to the next one while they return
+// ErrPassphraseRequired.
+func ChainPassphraseRetrievers(retrievers ...notary.PassRetriever) notary.PassRetriever {
+	return func(keyName string, alias string, createNew bool, numAttempts int) (string, bool, error) {
+		for _, retriever := range retrievers {
+			v, giveup, err := retriever(keyName, alias, createNew, numAttempts)
+			if _, ok := err.(ErrPassphraseRequired); ok {
+				continue
+			}
+			return v, giveup, err
+		}
+		return missingPassphrase(keyName, alias, createNew, numAttempts)
+	}
+}
+
+func trimNewline(s string) string {
+	s = strings.TrimSuffix(s, "\n")
+	return strings.TrimSuffix(s, "\r")
+}
+
+// passphraseService is a CryptoService whose key store asks passphrases
+// from passphrases. It returns the ErrPassphraseRequired of the retriever
+// rather than the errors notary replaces it with.
+type passphraseService struct {
+	signed.CryptoService
+	// mu serializes the calls to the key store, so that each sees the
+	// passphrase errors of its own.
+	mu          sync.Mutex
+	passphrases *passphraseRecorder
+}
+
+func newPassphraseService(retriever notary.PassRetriever, newKeyStore func(notary.PassRetriever) (trustmanager.KeyStore, error)) (*passphraseService, error) {
+	passphrases := &passphraseRecorder{retriever: retriever}
+	keyStore, err := newKeyStore(passphrases.retrieve)
+	if err != nil {
+		return nil, err
+	}
+	return &passphraseService{CryptoService: cryptoservice.NewCryptoService(keyStore), passphrases: passphrases}, nil
+}
+
+func (s *passphraseService) Create(role data.RoleName, gun data.GUN, algorithm string) (data.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, err := s.CryptoService.Create(role, gun, algorithm)
+	return key, s.passphrases.cause(err)
+}
+
+func (s *passphraseService) AddKey(role data.RoleName, gun data.GUN, key data.PrivateKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.passphrases.cause(s.CryptoService.AddKey(role, gun, key))
+}
+
+func (s *passphraseService) GetPrivateKey(keyID string) (data.PrivateKey, data.RoleName, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, role, err := s.CryptoService.GetPrivateKey(keyID)
+	return key, role, s.passphrases.cause(err)
+}
+
+func (s *passphraseService) GetKeyInfo(keyID string) (trustmanager.KeyInfo, error) {
+	if kis, ok := s.CryptoService.(keyInfoService); ok {
+		return kis.GetKeyInfo(keyID)
+	}
+	return trustmanager.KeyInfo{}, trustmanager.ErrKeyNotFound{KeyID: keyID}
+}
@@ -0,0 +1,100 @@
+package trust
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/theupdateframework/notary/tuf/data"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestNonInteractivePassphraseRetriever(t *testing.T) {
+	retriever := GetNonInteractivePassphraseRetriever("", "repo_passphrase")
+	passphrase, _, err := retriever("key", data.CanonicalTargetsRole.String(), false, 0)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(passphrase, "repo_passphrase"))
+
+	_, giveup, err := retriever("key", data.CanonicalRootRole.String(), false, 0)
+	assert.Check(t, giveup)
+	assert.Check(t, is.DeepEqual(err, ErrPassphraseRequired{KeyName: "key", Alias: "root"}))
+}
+
+func TestEnvPassphraseRetriever(t *testing.T) {
+	os.Setenv("TEST_ROOT_PASSPHRASE", "root_passphrase")
+	os.Setenv("TEST_DELEGATION_PASSPHRASE", "delegation_passphrase")
+	os.Setenv("TEST_REPOSITORY_PASSPHRASE", "repo_passphrase")
+	defer os.Unsetenv("TEST_ROOT_PASSPHRASE")
+	defer os.Unsetenv("TEST_DELEGATION_PASSPHRASE")
+	defer os.Unsetenv("TEST_REPOSITORY_PASSPHRASE")
+
+	retriever := EnvPassphraseRetriever("TEST")
+	passphrase, _, err := retriever("key", data.CanonicalRootRole.String(), false, 0)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(passphrase, "root_passphrase"))
+
+	passphrase, _, err = retriever("key", ReleasesRole.String(), false, 0)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(passphrase, "delegation_passphrase"))
+
+	passphrase, _, err = retriever("key", data.CanonicalSnapshotRole.String(), false, 0)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(passphrase, "repo_passphrase"))
+
+	os.Unsetenv("TEST_ROOT_PASSPHRASE")
+	_, _, err = retriever("key", data.CanonicalRootRole.String(), false, 0)
+	assert.Check(t, is.ErrorType(err, ErrPassphraseRequired{}))
+}
+
+func TestFilePassphraseRetriever(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "notary-test-")
+	assert.NilError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	rootFile := filepath.Join(tmpDir, "root")
+	assert.NilError(t, ioutil.WriteFile(rootFile, []byte("root_passphrase\n"), 0600))
+	defaultFile := filepath.Join(tmpDir, "default")
+	assert.NilError(t, ioutil.WriteFile(defaultFile, []byte("repo_passphrase"), 0600))
+
+	retriever := FilePassphraseRetriever(map[string]string{"root": rootFile, "default": defaultFile})
+	passphrase, _, err := retriever("key", data.CanonicalRootRole.String(), false, 0)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(passphrase, "root_passphrase"))
+
+	passphrase, _, err = retriever("key", data.CanonicalTargetsRole.String(), false, 0)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(passphrase, "repo_passphrase"))
+
+	retriever = FilePassphraseRetriever(map[string]string{"root": filepath.Join(tmpDir, "missing")})
+	_, _, err = retriever("key", data.CanonicalRootRole.String(), false, 0)
+	assert.Check(t, os.IsNotExist(err))
+}
+
+func TestCommandPassphraseRetriever(t *testing.T) {
+	retriever := CommandPassphraseRetriever("sh", "-c", `grep -q '"alias":"root"' && echo root_passphrase`)
+	passphrase, _, err := retriever("key", data.CanonicalRootRole.String(), false, 0)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(passphrase, "root_passphrase"))
+
+	_, _, err = retriever("key", data.CanonicalTargetsRole.String(), false, 0)
+	assert.ErrorContains(t, err, "passphrase helper sh failed")
+
+	retriever = CommandPassphraseRetriever("true")
+	_, _, err = retriever("key", data.CanonicalTargetsRole.String(), false, 0)
+	assert.Check(t, is.ErrorType(err, ErrPassphraseRequired{}))
+}
+
+func TestChainPassphraseRetrievers(t *testing.T) {
+	retriever := ChainPassphraseRetrievers(
+		GetNonInteractivePassphraseRetriever("", ""),
+		GetNonInteractivePassphraseRetriever("root_passphrase", ""),
+	)
+	passphrase, _, err := retriever("key", data.CanonicalRootRole.String(), false, 0)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(passphrase, "root_passphrase"))
+
+	_, _, err = retriever("key", data.CanonicalTargetsRole.String(), false, 0)
+	assert.Check(t, is.ErrorType(err, ErrPassphraseRequired{}))
+}
@@ -0,0 +1,30 @@
+package trust
+
+import (
+	"github.com/theupdateframework/notary/trustpinning"
+)
+
+// TrustPinningConfig pins the root certificates of repositories. A root is
+// validated against the first matching pin: its GUN in Certs, the longest
+// prefix in Certs, the longest prefix in CA, and finally trust on first use
+// unless DisableTOFU is set.
+type TrustPinningConfig struct {
+	// Certs maps a GUN, or a GUN prefix ending with "*" such as
+	// "docker.io/library/*", to the IDs of the root certificates it is
+	// pinned to.
+	Certs map[string][]string `json:"certs"`
+	// CA maps a GUN prefix to the path of a PEM bundle of the CAs which
+	// must issue the root certificates of its repositories.
+	CA map[string]string `json:"ca"`
+	// DisableTOFU rejects the root of a repository matching no pin,
+	// instead of trusting it the first time it is seen.
+	DisableTOFU bool `json:"disable_tofu"`
+}
+
+func (t TrustPinningConfig) notaryConfig() trustpinning.TrustPinConfig {
+	return trustpinning.TrustPinConfig{
+		Certs:       t.Certs,
+		CA:          t.CA,
+		DisableTOFU: t.DisableTOFU,
+	}
+}
@@ -0,0 +1,27 @@
+package trust
+
+import (
+	"testing"
+
+	"github.com/theupdateframework/notary/trustpinning"
+	"github.com/theupdateframework/notary/tuf/data"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestTrustPinningConfig(t *testing.T) {
+	pinning := TrustPinningConfig{
+		Certs:       map[string][]string{"example.com/foo/*": {"abc"}},
+		CA:          map[string]string{"example.com/bar": "/etc/notary/ca.pem"},
+		DisableTOFU: true,
+	}
+	config := pinning.notaryConfig()
+	assert.Check(t, is.DeepEqual(config.Certs, pinning.Certs))
+	assert.Check(t, is.DeepEqual(config.CA, pinning.CA))
+	assert.Check(t, config.DisableTOFU)
+
+	_, err := trustpinning.NewTrustPinChecker(config, data.GUN("example.com/foo/image"), true)
+	assert.NilError(t, err)
+	_, err = trustpinning.NewTrustPinChecker(config, data.GUN("example.com/other"), true)
+	assert.ErrorContains(t, err, "invalid trust pinning specified")
+}
@@ -0,0 +1,121 @@
+package trust
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/client/changelist"
+	"github.com/theupdateframework/notary/storage"
+	"github.com/theupdateframework/notary/trustmanager"
+	"github.com/theupdateframework/notary/tuf/data"
+	"github.com/theupdateframework/notary/tuf/signed"
+)
+
+const tufDir = "tuf"
+
+// newRepository returns the notary repository gun served by server through
+// rt. Like client.NewFileCachedRepository it caches the trust data and the
+// changelist in the trust directory of config, but it signs with the keys
+// of GetCryptoService. With config.InMemory they are kept in memory instead.
+func newRepository(config *Config, gun data.GUN, server string, rt http.RoundTripper) (client.Repository, error) {
+	trustDir := getTrustDirectory(config.RootPath)
+	gunDir := filepath.Join(trustDir, tufDir, filepath.FromSlash(gun.String()))
+
+	cache, err := metadataCache(config, gun)
+	if err != nil {
+		return nil, err
+	}
+
+	cs, err := GetCryptoService(config)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteStore, err := storage.NewHTTPStore(server+"/v2/"+gun.String()+"/_trust/tuf/", "", "json", "key", rt)
+	if err != nil {
+		// server is syntactically invalid
+		return nil, err
+	}
+
+	var cl changelist.Changelist
+	if config.InMemory {
+		cl = changelist.NewMemChangelist()
+	} else {
+		cl, err = changelist.NewFileChangelist(filepath.Join(gunDir, "changelist"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	repo, err := client.NewRepository(trustDir, gun, server, remoteStore, cache, config.TrustPinning.notaryConfig(), cs, cl)
+	if err != nil {
+		return nil, err
+	}
+	return &repository{Repository: repo, cache: cache}, nil
+}
+
+// repository is a notary repository built by newRepository, which keeps
+// the store caching its trust data.
+type repository struct {
+	client.Repository
+	cache storage.MetadataStore
+}
+
+// metadataCache returns the store caching the trust data of gun. In memory,
+// without a MemoryCache, the trust data is only cached for the lifetime of
+// the repository.
+func metadataCache(config *Config, gun data.GUN) (storage.MetadataStore, error) {
+	if config.InMemory {
+		if config.MemoryCache != nil {
+			return config.MemoryCache.store(gun), nil
+		}
+		return storage.NewMemoryStore(nil), nil
+	}
+	gunDir := filepath.Join(getTrustDirectory(config.RootPath), tufDir, filepath.FromSlash(gun.String()))
+	return storage.NewFileStore(filepath.Join(gunDir, "metadata"), "json")
+}
+
+// PublishedVersion returns the version of the snapshot last fetched by
+// repo, a repository returned by this package, from the notary server,
+// which identifies the published trust data.
+func PublishedVersion(repo client.Repository) (int, error) {
+	r, ok := repo.(*repository)
+	if !ok {
+		return 0, errors.Errorf("no trust data cache for repository %s", repo.GetGUN())
+	}
+	raw, err := r.cache.GetSized(data.CanonicalSnapshotRole.String(), -1)
+	if err != nil {
+		return 0, err
+	}
+	var snapshot data.SignedSnapshot
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return 0, err
+	}
+	return snapshot.Signed.Version, nil
+}
+
+// keyAlgorithmService is a CryptoService generating keys with a chosen
+// algorithm, where notary asks for ECDSA keys regardless of configuration.
+// Root keys are certified with X.509, which notary does not support for
+// ed25519 keys, so these remain ECDSA keys.
+type keyAlgorithmService struct {
+	signed.CryptoService
+	algorithm string
+}
+
+func (s keyAlgorithmService) Create(role data.RoleName, gun data.GUN, algorithm string) (data.PublicKey, error) {
+	if algorithm == data.ECDSAKey && !(role == data.CanonicalRootRole && s.algorithm == data.ED25519Key) {
+		algorithm = s.algorithm
+	}
+	return GenerateKey(s.CryptoService, role, gun, algorithm)
+}
+
+func (s keyAlgorithmService) GetKeyInfo(keyID string) (trustmanager.KeyInfo, error) {
+	if kis, ok := s.CryptoService.(keyInfoService); ok {
+		return kis.GetKeyInfo(keyID)
+	}
+	return trustmanager.KeyInfo{}, trustmanager.ErrKeyNotFound{KeyID: keyID}
+}
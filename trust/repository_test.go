@@ -0,0 +1,44 @@
+package trust
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/theupdateframework/notary/passphrase"
+	"github.com/theupdateframework/notary/tuf/data"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestPublishedVersion(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "notary-test-")
+	assert.NilError(t, err)
+	defer os.RemoveAll(tmpDir)
+	gun := data.GUN("example.com/foo")
+
+	for _, config := range []*Config{
+		{RootPath: tmpDir, PassRetriever: passphrase.ConstantRetriever("password")},
+		{InMemory: true},
+	} {
+		if config.InMemory {
+			config.CryptoService = NewMemoryCryptoService(config)
+		}
+		repo, err := newRepository(config, gun, "https://notary.example.com", nil)
+		assert.NilError(t, err)
+		_, err = PublishedVersion(repo)
+		assert.Check(t, err != nil)
+
+		// as fetched when publishing
+		cache := repo.(*repository).cache
+		snapshot := data.SignedSnapshot{Signed: data.Snapshot{SignedCommon: data.SignedCommon{Type: "Snapshot", Version: 7}}}
+		raw, err := json.Marshal(snapshot)
+		assert.NilError(t, err)
+		assert.NilError(t, cache.Set(data.CanonicalSnapshotRole.String(), raw))
+
+		version, err := PublishedVersion(repo)
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(version, 7))
+	}
+}
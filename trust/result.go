@@ -0,0 +1,31 @@
+package trust
+
+import (
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// SignResult describes a target signed and published by a TrustedRepository.
+type SignResult struct {
+	GUN    data.GUN
+	Name   string
+	Digest v1.Hash
+	Length int64
+	// Roles are the roles the target was signed into.
+	Roles []data.RoleName
+	// Version is the version of the snapshot published with the target.
+	Version int
+}
+
+// RevokeResult describes the signatures removed by a TrustedRepository.
+type RevokeResult struct {
+	GUN     data.GUN
+	Targets []RevokedTarget
+}
+
+// RevokedTarget is a target removed from the trust data, and the roles it
+// was removed from.
+type RevokedTarget struct {
+	Name  string
+	Roles []data.RoleName
+}
@@ -0,0 +1,128 @@
+package trust
+
+import (
+	"sync"
+	"time"
+
+	"github.com/theupdateframework/notary/client"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// TargetCache caches the targets verified for tags, by GUN and tag, so that
+// verifying a tag again does not download the trust data of its repository.
+// A target is fresh for the TTL of the cache, and can be used up to its
+// maximum staleness when the notary server cannot be reached. It is safe
+// for concurrent use, and a nil TargetCache caches nothing.
+type TargetCache struct {
+	ttl          time.Duration
+	maxStaleness time.Duration
+	now          func() time.Time
+
+	mu        sync.Mutex
+	targets   map[targetCacheKey]cachedTarget
+	nextSweep time.Time
+}
+
+type targetCacheKey struct {
+	gun data.GUN
+	tag string
+}
+
+type cachedTarget struct {
+	target *client.TargetWithRole
+	added  time.Time
+}
+
+// NewTargetCache returns a TargetCache whose targets are fresh for ttl, and
+// can be used when the notary server cannot be reached until maxStaleness,
+// which is at least ttl.
+func NewTargetCache(ttl, maxStaleness time.Duration) *TargetCache {
+	if maxStaleness < ttl {
+		maxStaleness = ttl
+	}
+	return &TargetCache{
+		ttl:          ttl,
+		maxStaleness: maxStaleness,
+		now:          time.Now,
+		targets:      make(map[targetCacheKey]cachedTarget),
+	}
+}
+
+// Get returns the target cached for tag in gun, if it is fresh.
+func (c *TargetCache) Get(gun data.GUN, tag string) (*client.TargetWithRole, bool) {
+	if c == nil {
+		return nil, false
+	}
+	return c.get(gun, tag, c.ttl)
+}
+
+// GetStale returns the target cached for tag in gun, if it is not older than
+// the maximum staleness of the cache.
+func (c *TargetCache) GetStale(gun data.GUN, tag string) (*client.TargetWithRole, bool) {
+	if c == nil {
+		return nil, false
+	}
+	return c.get(gun, tag, c.maxStaleness)
+}
+
+func (c *TargetCache) get(gun data.GUN, tag string, maxAge time.Duration) (*client.TargetWithRole, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := targetCacheKey{gun, tag}
+	cached, ok := c.targets[key]
+	if !ok {
+		return nil, false
+	}
+	age := c.now().Sub(cached.added)
+	if age > c.maxStaleness {
+		delete(c.targets, key)
+		return nil, false
+	}
+	if age > maxAge {
+		return nil, false
+	}
+	t := *cached.target
+	return &t, true
+}
+
+// Add caches target as verified for tag in gun.
+func (c *TargetCache) Add(gun data.GUN, tag string, target *client.TargetWithRole) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if now.After(c.nextSweep) {
+		// drop the targets too old to be used, once per maximum staleness
+		for key, cached := range c.targets {
+			if now.Sub(cached.added) > c.maxStaleness {
+				delete(c.targets, key)
+			}
+		}
+		c.nextSweep = now.Add(c.maxStaleness)
+	}
+	t := *target
+	c.targets[targetCacheKey{gun, tag}] = cachedTarget{target: &t, added: now}
+}
+
+// Invalidate removes the targets cached for tags in gun, or for every tag in
+// gun if none is given.
+func (c *TargetCache) Invalidate(gun data.GUN, tags ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(tags) == 0 {
+		for key := range c.targets {
+			if key.gun == gun {
+				delete(c.targets, key)
+			}
+		}
+		return
+	}
+	for _, tag := range tags {
+		delete(c.targets, targetCacheKey{gun, tag})
+	}
+}
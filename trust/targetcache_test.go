@@ -0,0 +1,57 @@
+package trust
+
+import (
+	"testing"
+	"time"
+
+	"github.com/theupdateframework/notary/client"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestTargetCache(t *testing.T) {
+	cache := NewTargetCache(time.Minute, 10*time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	target := &client.TargetWithRole{Target: client.Target{Name: "1.0", Length: 1}, Role: ReleasesRole}
+
+	cache.Add("example.com/foo", "1.0", target)
+	cache.Add("example.com/foo", "2.0", target)
+	cache.Add("example.com/bar", "1.0", target)
+	got, ok := cache.Get("example.com/foo", "1.0")
+	assert.Assert(t, ok)
+	assert.Check(t, is.DeepEqual(got, target))
+	_, ok = cache.Get("example.com/foo", "3.0")
+	assert.Check(t, !ok)
+
+	now = now.Add(2 * time.Minute)
+	_, ok = cache.Get("example.com/foo", "1.0")
+	assert.Check(t, !ok)
+	_, ok = cache.GetStale("example.com/foo", "1.0")
+	assert.Check(t, ok)
+
+	cache.Invalidate("example.com/foo", "1.0")
+	_, ok = cache.GetStale("example.com/foo", "1.0")
+	assert.Check(t, !ok)
+	_, ok = cache.GetStale("example.com/foo", "2.0")
+	assert.Check(t, ok)
+	cache.Invalidate("example.com/foo")
+	_, ok = cache.GetStale("example.com/foo", "2.0")
+	assert.Check(t, !ok)
+
+	now = now.Add(10 * time.Minute)
+	_, ok = cache.GetStale("example.com/bar", "1.0")
+	assert.Check(t, !ok)
+	assert.Check(t, is.Len(cache.targets, 0))
+
+	var nilCache *TargetCache
+	nilCache.Add("example.com/foo", "1.0", target)
+	_, ok = nilCache.Get("example.com/foo", "1.0")
+	assert.Check(t, !ok)
+	nilCache.Invalidate("example.com/foo")
+}
+
+func TestNewTargetCache(t *testing.T) {
+	cache := NewTargetCache(time.Minute, time.Second)
+	assert.Check(t, is.Equal(cache.maxStaleness, time.Minute))
+}
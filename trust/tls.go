@@ -0,0 +1,98 @@
+package trust
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+
+	"github.com/docker/go-connections/tlsconfig"
+	"github.com/pkg/errors"
+)
+
+// TLSConfig is the TLS policy for the notary server. The server certificate
+// is verified against the system CAs, the CAs of the tls/<host> certificate
+// directory and CA or CAFile. Client certificates are read from the
+// certificate directory and from Cert and Key, or CertFile and KeyFile.
+type TLSConfig struct {
+	// CA is a PEM bundle of CAs trusted for the notary server.
+	CA string `json:"ca"`
+	// CAFile is the path of a PEM bundle of CAs trusted for the notary
+	// server.
+	CAFile string `json:"ca_file"`
+	// Cert and Key are a PEM client certificate and its private key.
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+	// CertFile and KeyFile are the paths of a PEM client certificate and
+	// its private key.
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// InsecureSkipVerify disables the verification of the notary server
+	// certificate. It is meant for local testing only.
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+}
+
+// notaryTLSConfig returns the TLS configuration for server, applying the
+// certificate directory of config and its TLS policy.
+func (c *Config) notaryTLSConfig(server string) (*tls.Config, error) {
+	cfg := tlsconfig.ClientDefault()
+
+	// Get certificate base directory, which in memory is optional
+	if c.RootPath != "" || !c.InMemory {
+		certDir, err := certificateDirectory(c.RootPath, server)
+		if err != nil {
+			return nil, err
+		}
+		c.Log().Debugf("reading certificate directory: %s", certDir)
+		if err := readCertsDirectory(cfg, certDir, c.Log()); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.TLS.apply(cfg); err != nil {
+		return nil, err
+	}
+	if c.TLS.InsecureSkipVerify {
+		c.Log().Warnf("INSECURE: TLS certificate verification of notary server %s is disabled, trust data can be tampered with in transit", server)
+	}
+	return cfg, nil
+}
+
+func (t TLSConfig) apply(cfg *tls.Config) error {
+	ca := []byte(t.CA)
+	if t.CAFile != "" {
+		b, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return errors.Wrap(err, "failed to read CA bundle")
+		}
+		ca = append(append(ca, '\n'), b...)
+	}
+	if len(ca) > 0 {
+		if cfg.RootCAs == nil {
+			systemPool, err := tlsconfig.SystemCertPool()
+			if err != nil {
+				return errors.Wrap(err, "unable to get system cert pool")
+			}
+			cfg.RootCAs = systemPool
+		}
+		if !cfg.RootCAs.AppendCertsFromPEM(ca) {
+			return errors.New("no CA certificate found in CA bundle")
+		}
+	}
+
+	switch {
+	case t.Cert != "" || t.Key != "":
+		cert, err := tls.X509KeyPair([]byte(t.Cert), []byte(t.Key))
+		if err != nil {
+			return errors.Wrap(err, "invalid client certificate")
+		}
+		cfg.Certificates = append(cfg.Certificates, cert)
+	case t.CertFile != "" || t.KeyFile != "":
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return errors.Wrap(err, "invalid client certificate")
+		}
+		cfg.Certificates = append(cfg.Certificates, cert)
+	}
+
+	cfg.InsecureSkipVerify = t.InsecureSkipVerify
+	return nil
+}
@@ -0,0 +1,73 @@
+package trust
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func testCertificate(t *testing.T) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "notary-test"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NilError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NilError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestNotaryTLSConfigVerifiesByDefault(t *testing.T) {
+	config := &Config{RootPath: "/nonexistent"}
+	cfg, err := config.notaryTLSConfig("https://notary.example.com")
+	assert.NilError(t, err)
+	assert.Check(t, !cfg.InsecureSkipVerify)
+	assert.Check(t, is.Len(cfg.Certificates, 0))
+}
+
+func TestNotaryTLSConfig(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "notary-test-")
+	assert.NilError(t, err)
+	defer os.RemoveAll(tmpDir)
+	certPEM, keyPEM := testCertificate(t)
+	certFile := filepath.Join(tmpDir, "client.pem")
+	keyFile := filepath.Join(tmpDir, "client-key.pem")
+	assert.NilError(t, ioutil.WriteFile(certFile, certPEM, 0600))
+	assert.NilError(t, ioutil.WriteFile(keyFile, keyPEM, 0600))
+
+	config := &Config{RootPath: tmpDir, TLS: TLSConfig{CA: string(certPEM), Cert: string(certPEM), Key: string(keyPEM)}}
+	cfg, err := config.notaryTLSConfig("https://notary.example.com")
+	assert.NilError(t, err)
+	assert.Check(t, cfg.RootCAs != nil)
+	assert.Check(t, is.Len(cfg.Certificates, 1))
+
+	config.TLS = TLSConfig{CAFile: certFile, CertFile: certFile, KeyFile: keyFile, InsecureSkipVerify: true}
+	cfg, err = config.notaryTLSConfig("https://notary.example.com")
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(cfg.Certificates, 1))
+	assert.Check(t, cfg.InsecureSkipVerify)
+
+	config.TLS = TLSConfig{CA: "not a certificate"}
+	_, err = config.notaryTLSConfig("https://notary.example.com")
+	assert.Error(t, err, "no CA certificate found in CA bundle")
+}
@@ -0,0 +1,57 @@
+package trust
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/pkg/errors"
+	"github.com/theupdateframework/notary/tuf/data"
+)
+
+// contextTransport binds every request it sends to a context, so that
+// cancellation and deadlines reach round trips issued by libraries which
+// do not accept a context themselves (notary client, registry transports).
+type contextTransport struct {
+	ctx   context.Context
+	inner http.RoundTripper
+}
+
+// NewContextTransport returns a RoundTripper which sends every request
+// through inner using ctx.
+func NewContextTransport(ctx context.Context, inner http.RoundTripper) http.RoundTripper {
+	if inner == nil {
+		inner = http.DefaultTransport
+	}
+	return &contextTransport{ctx: ctx, inner: inner}
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return t.inner.RoundTrip(req.WithContext(t.ctx))
+}
+
+// notaryTransport authenticates the requests to the notary server with the
+// Docker registry token flow: it follows the WWW-Authenticate challenge of
+// the server itself, and fetches a token for gun with actions (e.g.
+// "pull,push"), which it caches and refreshes when it is rejected.
+func notaryTransport(server string, gun data.GUN, auth authn.Authenticator, base http.RoundTripper, actions string) (http.RoundTripper, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+	registry, err := name.NewRegistry(u.Host, name.WeakValidation)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid trust server %s", server)
+	}
+	if auth == nil {
+		auth = authn.Anonymous
+	}
+	scopes := []string{"repository:" + gun.String() + ":" + actions}
+	return transport.New(registry, auth, base, scopes)
+}
@@ -0,0 +1,63 @@
+package trust
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"gotest.tools/assert"
+	is "gotest.tools/assert/cmp"
+)
+
+func TestContextTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := http.Client{Transport: NewContextTransport(ctx, http.DefaultTransport)}
+
+	resp, err := c.Get(server.URL)
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Check(t, is.Equal(resp.StatusCode, http.StatusOK))
+
+	cancel()
+	_, err = c.Get(server.URL)
+	assert.ErrorContains(t, err, context.Canceled.Error())
+}
+
+func TestNotaryTransportTokenAuth(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			user, pass, _ := r.BasicAuth()
+			if user != "notary" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			assert.Check(t, is.Equal(r.FormValue("scope"), "repository:example.com/foo/image:push,pull"))
+			assert.Check(t, is.Equal(r.FormValue("service"), "notary-server"))
+			w.Write([]byte(`{"token": "abc"}`))
+		default:
+			if r.Header.Get("Authorization") != "Bearer abc" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="notary-server"`)
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}
+	}))
+	defer server.Close()
+
+	auth := &authn.Basic{Username: "notary", Password: "secret"}
+	rt, err := notaryTransport(server.URL, "example.com/foo/image", auth, server.Client().Transport, transport.PushScope)
+	assert.NilError(t, err)
+
+	c := http.Client{Transport: rt}
+	resp, err := c.Get(server.URL + "/v2/example.com/foo/image/_trust/tuf/root.json")
+	assert.NilError(t, err)
+	resp.Body.Close()
+	assert.Check(t, is.Equal(resp.StatusCode, http.StatusOK))
+}
@@ -1,8 +1,8 @@
 package trust
 
 import (
+	"context"
 	"crypto/tls"
-	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -18,17 +18,12 @@ import (
 	"github.com/docker/go-connections/tlsconfig"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
-	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/theupdateframework/notary"
 	"github.com/theupdateframework/notary/client"
 	"github.com/theupdateframework/notary/passphrase"
-	"github.com/theupdateframework/notary/storage"
-	"github.com/theupdateframework/notary/trustmanager"
-	"github.com/theupdateframework/notary/trustpinning"
 	"github.com/theupdateframework/notary/tuf/data"
-	"github.com/theupdateframework/notary/tuf/signed"
 )
 
 var (
@@ -42,6 +37,9 @@ var (
 	NotaryServer = "https://notary.docker.io"
 )
 
+// dockerHubGUNPrefix prefixes the GUNs of Docker Hub repositories.
+const dockerHubGUNPrefix = "docker.io/"
+
 // Server returns the base URL for the trust server.
 func Server(serverUrl string, repoInfo *name.Registry) (string, error) {
 	if serverUrl != "" {
@@ -62,56 +60,119 @@ func Server(serverUrl string, repoInfo *name.Registry) (string, error) {
 // information needed to operate on a notary repository.
 // It creates an HTTP transport providing authentication support.
 func GetNotaryRepository(ref name.Reference, auth authn.Authenticator, repoInfo *name.Registry, config *Config) (client.Repository, error) {
+	return GetNotaryRepositoryWithContext(context.Background(), ref, auth, repoInfo, config)
+}
+
+// GetNotaryRepositoryWithContext is like GetNotaryRepository, but every HTTP
+// round trip made by the returned repository, including the authentication
+// handshake, is bound to ctx.
+func GetNotaryRepositoryWithContext(ctx context.Context, ref name.Reference, auth authn.Authenticator, repoInfo *name.Registry, config *Config) (client.Repository, error) {
+	if config.ReadOnly {
+		return nil, ErrReadOnly
+	}
+	return getNotaryRepository(ctx, ref, auth, repoInfo, config, ActionsPushAndPull)
+}
+
+// GetReadOnlyNotaryRepository is like GetNotaryRepositoryWithContext, but
+// only authenticates to the notary server with pull scope: the returned
+// repository can read trust data but not publish it.
+func GetReadOnlyNotaryRepository(ctx context.Context, ref name.Reference, auth authn.Authenticator, repoInfo *name.Registry, config *Config) (client.Repository, error) {
+	return getNotaryRepository(ctx, ref, auth, repoInfo, config, ActionsPullOnly)
+}
+
+func getNotaryRepository(ctx context.Context, ref name.Reference, auth authn.Authenticator, repoInfo *name.Registry, config *Config, actions []string) (client.Repository, error) {
 	server, err := Server(config.ServerUrl, repoInfo)
 	if err != nil {
 		return nil, err
 	}
-
-	var cfg = tlsconfig.ClientDefault()
-	if repoInfo.Scheme() == "https" {
-		cfg.InsecureSkipVerify = true
-	}
-
-	// Get certificate base directory
-	certDir, err := certificateDirectory(config.RootPath, server)
+	base, err := config.notaryBaseTransport(server)
 	if err != nil {
 		return nil, err
 	}
-	log.Infof("reading certificate directory: %s \n", certDir)
+	return newNotaryRepository(ref, auth, config, server, NewContextTransport(ctx, base), actions)
+}
 
-	if err := readCertsDirectory(cfg, certDir); err != nil {
-		return nil, err
+// notaryBaseTransport returns the transport to server: Transport, the
+// keep-alive transport shared through RepositoryCache, or a new transport
+// built from the TLS policy.
+func (c *Config) notaryBaseTransport(server string) (http.RoundTripper, error) {
+	if c.Transport != nil {
+		return c.Transport, nil
+	}
+	if c.RepositoryCache != nil {
+		return c.RepositoryCache.notaryTransport(server, func() (*http.Transport, error) {
+			return c.newNotaryTransport(server, true)
+		})
 	}
+	return c.newNotaryTransport(server, false)
+}
 
-	base := &http.Transport{
+// newNotaryTransport returns a transport to server built from the TLS
+// policy. Idle keep-alive connections are closed after a while.
+func (c *Config) newNotaryTransport(server string, keepAlive bool) (*http.Transport, error) {
+	cfg, err := c.notaryTLSConfig(server)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
-		Dial: (&net.Dialer{
+		DialContext: (&net.Dialer{
 			Timeout:   30 * time.Second,
 			KeepAlive: 30 * time.Second,
 			DualStack: true,
-		}).Dial,
+		}).DialContext,
 		TLSHandshakeTimeout: 10 * time.Second,
 		TLSClientConfig:     cfg,
-		DisableKeepAlives:   true,
-	}
+		DisableKeepAlives:   !keepAlive,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}, nil
+}
 
-	repo := ref.Context()
-	scopes := []string{repo.Scope(transport.PushScope)}
-	tr, err := transport.New(repo.Registry, auth, base, scopes)
+// newNotaryRepository authenticates to server through rt and returns the
+// notary repository of ref.
+func newNotaryRepository(ref name.Reference, auth authn.Authenticator, config *Config, server string, rt http.RoundTripper, actions []string) (client.Repository, error) {
+	gun := config.GUN(ref)
+	notaryAuth, err := config.notaryAuth(server, auth)
 	if err != nil {
 		return nil, err
 	}
-	ecrURIwithoutTag := strings.Split(fmt.Sprintf("%s",ref), ":")[0]
+	tr, err := notaryTransport(server, gun, notaryAuth, rt, strings.Join(actions, ","))
+	if err != nil {
+		return nil, connectionError(gun.String(), err)
+	}
+
+	config.Log().Infof("using ref as certificate directory: %s \n", gun)
 
-	log.Infof("using ref as certificate directory: %s \n", fmt.Sprintf("%s", ecrURIwithoutTag))
+	return newRepository(config, gun, server, tr)
+}
 
-	return client.NewFileCachedRepository(
-		getTrustDirectory(config.RootPath),
-		data.GUN(fmt.Sprintf("%s", ecrURIwithoutTag)),
-		server,
-		tr,
-		GetPassphraseRetriever(os.Stdin, os.Stderr, config.RootPassphrase, config.RepositoryPassphrase),
-		trustpinning.TrustPinConfig{})
+// GUN returns the globally unique name of the trust collection of ref: its
+// repository name, without tag or digest. Docker Hub repositories are named
+// docker.io/..., as the Docker Hub notary server expects.
+func GUN(ref name.Reference) data.GUN {
+	repo := ref.Context()
+	if repo.RegistryStr() == name.DefaultRegistry {
+		return data.GUN(dockerHubGUNPrefix + repo.RepositoryStr())
+	}
+	return data.GUN(repo.Name())
+}
+
+// GUN returns the GUN of ref, rewritten by the longest matching rule of
+// GUNMapping.
+func (c *Config) GUN(ref name.Reference) data.GUN {
+	gun := GUN(ref).String()
+	match, replacement := "", ""
+	for prefix, r := range c.GUNMapping {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if (gun == prefix || strings.HasPrefix(gun, prefix+"/")) && len(prefix) > len(match) {
+			match, replacement = prefix, strings.TrimSuffix(r, "/")
+		}
+	}
+	if match == "" {
+		return data.GUN(gun)
+	}
+	return data.GUN(replacement + gun[len(match):])
 }
 
 // GetSignableRoles returns a list of roles for which we have valid signing
@@ -171,7 +232,12 @@ func GetPassphraseRetriever(in io.Reader, out io.Writer, rootPassphrase string,
 		"default":  "repository",
 	}
 
-	baseRetriever := passphrase.PromptRetrieverWithInOut(in, out, aliasMap)
+	return configPassphraseRetriever(rootPassphrase, repoPassphrase, passphrase.PromptRetrieverWithInOut(in, out, aliasMap))
+}
+
+// configPassphraseRetriever returns a passphrase retriever providing the
+// configured passphrases, and asking baseRetriever for the others.
+func configPassphraseRetriever(rootPassphrase string, repoPassphrase string, baseRetriever notary.PassRetriever) notary.PassRetriever {
 	env := map[string]string{
 		"root":     rootPassphrase,
 		"snapshot": repoPassphrase,
@@ -191,6 +257,19 @@ func GetPassphraseRetriever(in io.Reader, out io.Writer, rootPassphrase string,
 	}
 }
 
+// passphraseRetriever returns the retriever configured on c, falling back to
+// one prompting on stdin for passphrases missing from the configuration,
+// unless c is non-interactive.
+func (c *Config) passphraseRetriever() notary.PassRetriever {
+	if c.PassRetriever != nil {
+		return c.PassRetriever
+	}
+	if c.NonInteractive {
+		return GetNonInteractivePassphraseRetriever(c.RootPassphrase, c.RepositoryPassphrase)
+	}
+	return GetPassphraseRetriever(os.Stdin, os.Stderr, c.RootPassphrase, c.RepositoryPassphrase)
+}
+
 // getTrustDirectory returns the base trust directory name
 func getTrustDirectory(configDir string) string {
 	return filepath.Join(configDir, "trust")
@@ -210,8 +289,8 @@ func certificateDirectory(configDir string, server string) (string, error) {
 
 // readCertsDirectory reads the directory for TLS certificates
 // including roots and certificate pairs and updates the
-// provided TLS configuration.
-func readCertsDirectory(tlsConfig *tls.Config, directory string) error {
+// provided TLS configuration, logging the files read to logger.
+func readCertsDirectory(tlsConfig *tls.Config, directory string, logger log.FieldLogger) error {
 	fs, err := ioutil.ReadDir(directory)
 	if err != nil && !os.IsNotExist(err) {
 		return err
@@ -226,7 +305,7 @@ func readCertsDirectory(tlsConfig *tls.Config, directory string) error {
 				}
 				tlsConfig.RootCAs = systemPool
 			}
-			log.Debugf("crt: %s", filepath.Join(directory, f.Name()))
+			logger.Debugf("crt: %s", filepath.Join(directory, f.Name()))
 			data, err := ioutil.ReadFile(filepath.Join(directory, f.Name()))
 			if err != nil {
 				return err
@@ -236,7 +315,7 @@ func readCertsDirectory(tlsConfig *tls.Config, directory string) error {
 		if strings.HasSuffix(f.Name(), ".cert") {
 			certName := f.Name()
 			keyName := certName[:len(certName)-5] + ".key"
-			log.Debugf("cert: %s", filepath.Join(directory, f.Name()))
+			logger.Debugf("cert: %s", filepath.Join(directory, f.Name()))
 			if !hasFile(fs, keyName) {
 				return fmt.Errorf("missing key %s for client certificate %s. Note that CA certificates should use the extension .crt", keyName, certName)
 			}
@@ -249,7 +328,7 @@ func readCertsDirectory(tlsConfig *tls.Config, directory string) error {
 		if strings.HasSuffix(f.Name(), ".key") {
 			keyName := f.Name()
 			certName := keyName[:len(keyName)-4] + ".cert"
-			log.Debugf("key: %s", filepath.Join(directory, f.Name()))
+			logger.Debugf("key: %s", filepath.Join(directory, f.Name()))
 			if !hasFile(fs, certName) {
 				return fmt.Errorf("Missing client certificate %s for key %s", certName, keyName)
 			}
@@ -267,36 +346,3 @@ func hasFile(files []os.FileInfo, name string) bool {
 	}
 	return false
 }
-
-// NotaryError formats an error message received from the notary service
-func NotaryError(repoName string, err error) error {
-	switch err.(type) {
-	case *json.SyntaxError:
-		log.Debugf("Notary syntax error: %s", err)
-		return errors.Errorf("Error: no trust data available for remote repository %s. Try running notary server and setting DOCKER_CONTENT_TRUST_SERVER to its HTTPS address?", repoName)
-	case signed.ErrExpired:
-		return errors.Errorf("Error: remote repository %s out-of-date: %v", repoName, err)
-	case trustmanager.ErrKeyNotFound:
-		return errors.Errorf("Error: signing keys for remote repository %s not found: %v", repoName, err)
-	case storage.NetworkError:
-		return errors.Errorf("Error: error contacting notary server: %v", err)
-	case storage.ErrMetaNotFound:
-		return errors.Errorf("Error: trust data missing for remote repository %s or remote repository not found: %v", repoName, err)
-	case trustpinning.ErrRootRotationFail, trustpinning.ErrValidationFail, signed.ErrInvalidKeyType:
-		return errors.Errorf("Warning: potential malicious behavior - trust data mismatch for remote repository %s: %v", repoName, err)
-	case signed.ErrNoKeys:
-		return errors.Errorf("Error: could not find signing keys for remote repository %s, or could not decrypt signing key: %v", repoName, err)
-	case signed.ErrLowVersion:
-		return errors.Errorf("Warning: potential malicious behavior - trust data version is lower than expected for remote repository %s: %v", repoName, err)
-	case signed.ErrRoleThreshold:
-		return errors.Errorf("Warning: potential malicious behavior - trust data has insufficient signatures for remote repository %s: %v", repoName, err)
-	case client.ErrRepositoryNotExist:
-		return errors.Errorf("Error: remote trust data does not exist for %s: %v", repoName, err)
-	case signed.ErrInsufficientSignatures:
-		return errors.Errorf("Error: could not produce valid signature for %s.  If Yubikey was used, was touch input provided?: %v", repoName, err)
-	case client.ErrNoSuchTarget:
-		return errors.Errorf("Error: remote trust target does not exist for %s: %v", repoName, err)
-	}
-
-	return err
-}
@@ -1,7 +1,10 @@
 package trust
 
 import (
+	"context"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -69,4 +72,73 @@ func TestGetSignableRolesError(t *testing.T) {
 	target := client.Target{}
 	_, err = GetSignableRoles(notaryRepo, &target)
 	assert.Error(t, err, "client is offline")
-}
\ No newline at end of file
+}
+func TestGUN(t *testing.T) {
+	cases := map[string]data.GUN{
+		"localhost:5000/foo/bar:tag":              "localhost:5000/foo/bar",
+		"dockerhub.com/foo/image:latest":          "dockerhub.com/foo/image",
+		"dockerhub.com/foo/image":                 "dockerhub.com/foo/image",
+		"alpine:3.10":                             "docker.io/library/alpine",
+		"docker.io/foo/image:latest":              "docker.io/foo/image",
+		"dockerhub.com/foo/image@sha256:" + zeros: "dockerhub.com/foo/image",
+	}
+	for s, expected := range cases {
+		ref, err := name.ParseReference(s, name.WeakValidation)
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(GUN(ref), expected), s)
+	}
+}
+
+const zeros = "0000000000000000000000000000000000000000000000000000000000000000"
+
+func TestConfigGUNMapping(t *testing.T) {
+	config := &Config{GUNMapping: map[string]string{
+		"mirror.example.com":          "registry.example.com",
+		"mirror.example.com/private/": "private.example.com/",
+	}}
+	cases := map[string]data.GUN{
+		"mirror.example.com/foo/image:latest":     "registry.example.com/foo/image",
+		"mirror.example.com/private/image:latest": "private.example.com/image",
+		"mirror.example.com2/foo/image:latest":    "mirror.example.com2/foo/image",
+		"other.example.com/foo/image:latest":      "other.example.com/foo/image",
+	}
+	for s, expected := range cases {
+		ref, err := name.ParseReference(s, name.WeakValidation)
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(config.GUN(ref), expected), s)
+	}
+}
+
+func TestGetReadOnlyNotaryRepository(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "notary-test-")
+	assert.NilError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	var scopes []string
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			scopes = append(scopes, r.FormValue("scope"))
+			w.Write([]byte(`{"token": "abc"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer abc" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="notary-server"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	ref, _ := name.ParseReference("example.com/foo/image:latest", name.WeakValidation)
+	repoInfo := ref.Context().Registry
+	config := &Config{RootPath: tmpDir, ServerUrl: server.URL, Transport: server.Client().Transport, ReadOnly: true}
+
+	_, err = GetNotaryRepositoryWithContext(context.Background(), ref, nil, &repoInfo, config)
+	assert.Check(t, is.Equal(err, ErrReadOnly))
+
+	// anonymous access, with pull scope
+	notaryRepo, err := GetReadOnlyNotaryRepository(context.Background(), ref, nil, &repoInfo, config)
+	assert.NilError(t, err)
+	assert.Check(t, is.Equal(notaryRepo.GetGUN(), data.GUN("example.com/foo/image")))
+	assert.Check(t, is.DeepEqual(scopes, []string{"repository:example.com/foo/image:pull"}))
+}